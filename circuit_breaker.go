@@ -1,8 +1,68 @@
-// wrapper for circuit breaker configuration, currently using github.com/afex/hystrix-go
+// wrapper for circuit breaker, implemented by a built-in sliding window circuit breaker or github.com/afex/hystrix-go
 
 package connect
 
-import "github.com/afex/hystrix-go/hystrix"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/afex/hystrix-go/hystrix"
+	"github.com/imdario/mergo"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const circuitBreakerBucketCount = 10
+
+var (
+	// ErrCircuitOpen returned when the circuit is open and the call is not executed
+	ErrCircuitOpen = errors.New("circuit open")
+	// ErrMaxConcurrency returned when the call is not executed because too many calls of the same circuit are running
+	ErrMaxConcurrency = errors.New("max concurrency")
+)
+
+// CircuitState state of a circuit
+type CircuitState int
+
+const (
+	// CircuitClosed the calls are executed and their results are counted
+	CircuitClosed CircuitState = iota
+	// CircuitOpen the calls are rejected until the sleep window is passed
+	CircuitOpen
+	// CircuitHalfOpen a limited number of calls are executed to test the recovery
+	CircuitHalfOpen
+)
+
+// String returns the state name
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreaker executes functions guarded by a circuit per name
+type CircuitBreaker interface {
+	// Execute runs fn when the circuit of the name allows it, an error returned by fn counts as a failure.
+	// ErrCircuitOpen or ErrMaxConcurrency is returned when fn is not executed.
+	Execute(ctx context.Context, name string, fn func(ctx context.Context) error) error
+
+	// State returns the current state of the circuit of the name
+	State(name string) CircuitState
+
+	// CircuitStates returns the snapshot of every circuit, keyed by the circuit name
+	CircuitStates() map[string]CircuitSnapshot
+}
 
 // CircuitSetting is used to tune circuit settings at runtime
 type CircuitSetting struct {
@@ -22,8 +82,20 @@ type CircuitSetting struct {
 	ErrorPercentThreshold int `json:"error_percent_threshold"`
 }
 
+// Validate returns the joined errors of every invalid field
+func (s *CircuitSetting) Validate() error {
+	v := &optionsValidator{}
+	v.check(s.Timeout >= 0, "Timeout", "must not be negative")
+	v.check(s.MaxConcurrentRequests >= 0, "MaxConcurrentRequests", "must not be negative")
+	v.check(s.RequestVolumeThreshold >= 0, "RequestVolumeThreshold", "must not be negative")
+	v.check(s.SleepWindow >= 0, "SleepWindow", "must not be negative")
+	v.check(s.ErrorPercentThreshold >= 0 && s.ErrorPercentThreshold <= 100, "ErrorPercentThreshold", "must be between 0 and 100")
+	return v.err()
+}
+
 // ConfigureCircuitBreaker is used to set the default value for any method
-// hystrix will copy this value as the setting when setting for a command is not found
+// hystrix will copy this value as the setting when setting for a command is not found.
+// It only affects HystrixCircuitBreaker.
 func ConfigureCircuitBreaker(setting *CircuitSetting) {
 	hystrix.DefaultTimeout = setting.Timeout
 	hystrix.DefaultMaxConcurrent = setting.MaxConcurrentRequests
@@ -31,3 +103,587 @@ func ConfigureCircuitBreaker(setting *CircuitSetting) {
 	hystrix.DefaultVolumeThreshold = setting.RequestVolumeThreshold
 	hystrix.DefaultSleepWindow = setting.SleepWindow
 }
+
+// isShortCircuited checks if the call is rejected by the circuit breaker without being executed
+func isShortCircuited(err error) bool {
+	return errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrMaxConcurrency)
+}
+
+// shortCircuitReason returns the message of ErrCircuitOpen or ErrMaxConcurrency matched by err,
+// so the reason is the same whatever the circuit breaker implementation
+func shortCircuitReason(err error) string {
+	if errors.Is(err, ErrMaxConcurrency) {
+		return ErrMaxConcurrency.Error()
+	}
+	return ErrCircuitOpen.Error()
+}
+
+// hystrixRejectionError the hystrix rejection error matching both the hystrix error and ErrCircuitOpen or ErrMaxConcurrency,
+// the message is the hystrix one, e.g. hystrix: circuit open
+type hystrixRejectionError struct {
+	hystrixErr error
+	err        error
+}
+
+func (e *hystrixRejectionError) Error() string {
+	return e.hystrixErr.Error()
+}
+
+func (e *hystrixRejectionError) Unwrap() []error {
+	return []error{e.hystrixErr, e.err}
+}
+
+// recordShortCircuit logs and adds a span event when the call is rejected by the circuit breaker
+func recordShortCircuit(ctx context.Context, name string, err error) {
+	if !isShortCircuited(err) {
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"circuit": name,
+		"reason":  shortCircuitReason(err),
+	}).Warn("circuit breaker short-circuited the call")
+
+	trace.SpanFromContext(ctx).AddEvent("circuit breaker short-circuited", trace.WithAttributes(
+		attribute.String("circuit.name", name),
+		attribute.String("circuit.reason", shortCircuitReason(err)),
+	))
+}
+
+// HystrixCircuitBreaker adapts github.com/afex/hystrix-go to CircuitBreaker.
+// The circuits are hystrix globals, so they're shared by every HystrixCircuitBreaker,
+// and so are their snapshots and the OnHystrixCircuitStateChange callback.
+// The hystrix state is polled by Execute, State and CircuitStates, and hystrix doesn't expose the half-open state.
+type HystrixCircuitBreaker struct{}
+
+// hystrixCircuits tracks the state and the counts of the hystrix circuits
+var hystrixCircuits = &hystrixCircuitTracker{
+	circuits: map[string]*hystrixCircuit{},
+}
+
+// hystrixCircuitWindow is the rolling window of the hystrix metrics
+const hystrixCircuitWindow = 10 * time.Second
+
+type hystrixCircuitTracker struct {
+	mu       sync.Mutex
+	circuits map[string]*hystrixCircuit
+	notifier circuitStateNotifier
+}
+
+// hystrixCircuit the last polled state and the counts of a hystrix circuit, guarded by the hystrixCircuitTracker lock
+type hystrixCircuit struct {
+	state               CircuitState
+	consecutiveFailures int
+	counts              circuitCounts
+}
+
+// OnHystrixCircuitStateChange sets the callback called after a hystrix circuit changes its state,
+// e.g. the circuits of the connectors using the default circuit breaker. See SlidingWindowCircuitBreakerOptions.OnStateChange for the delivery.
+func OnHystrixCircuitStateChange(fn func(name string, from, to CircuitState)) {
+	hystrixCircuits.notifier.setCallback(fn)
+}
+
+// ConfigureHystrixCircuitMetrics sets if the hystrix circuits record the circuit_breaker.state_changes
+// and circuit_breaker.rejections metrics, they're not recorded by default
+func ConfigureHystrixCircuitMetrics(enabled bool) {
+	metrics := newCircuitBreakerMetrics(enabled)
+
+	hystrixCircuits.mu.Lock()
+	hystrixCircuits.notifier.metrics = metrics
+	hystrixCircuits.mu.Unlock()
+}
+
+// NewHystrixCircuitBreaker :nodoc:
+func NewHystrixCircuitBreaker() *HystrixCircuitBreaker {
+	return &HystrixCircuitBreaker{}
+}
+
+// Configure sets the hystrix setting of the circuit of the name
+func (*HystrixCircuitBreaker) Configure(name string, setting *CircuitSetting) {
+	hystrix.ConfigureCommand(name, hystrix.CommandConfig{
+		Timeout:                setting.Timeout,
+		MaxConcurrentRequests:  setting.MaxConcurrentRequests,
+		RequestVolumeThreshold: setting.RequestVolumeThreshold,
+		SleepWindow:            setting.SleepWindow,
+		ErrorPercentThreshold:  setting.ErrorPercentThreshold,
+	})
+}
+
+// Execute runs fn inside hystrix.DoC, the rejections match both the hystrix errors,
+// e.g. hystrix.ErrCircuitOpen, and ErrCircuitOpen or ErrMaxConcurrency
+func (*HystrixCircuitBreaker) Execute(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	err := hystrix.DoC(ctx, name, fn, nil)
+	switch {
+	case errors.Is(err, hystrix.ErrCircuitOpen):
+		err = &hystrixRejectionError{hystrixErr: err, err: ErrCircuitOpen}
+	case errors.Is(err, hystrix.ErrMaxConcurrency):
+		err = &hystrixRejectionError{hystrixErr: err, err: ErrMaxConcurrency}
+	}
+
+	hystrixCircuits.record(ctx, name, err)
+	return err
+}
+
+// State returns CircuitOpen or CircuitClosed, hystrix doesn't expose the half-open state
+func (*HystrixCircuitBreaker) State(name string) CircuitState {
+	return hystrixCircuits.state(name)
+}
+
+// CircuitStates returns the snapshot of every hystrix circuit executed by a HystrixCircuitBreaker, keyed by the circuit name
+func (*HystrixCircuitBreaker) CircuitStates() map[string]CircuitSnapshot {
+	return hystrixCircuits.snapshots()
+}
+
+// record counts the result of an executed call, the short-circuited calls are only recorded as rejections
+func (t *hystrixCircuitTracker) record(ctx context.Context, name string, err error) {
+	t.mu.Lock()
+	c := t.circuit(name)
+	if isShortCircuited(err) {
+		t.notifier.metrics.recordRejection(ctx, name, err)
+	} else {
+		c.counts.add(time.Now(), hystrixCircuitWindow, err == nil)
+		if err == nil {
+			c.consecutiveFailures = 0
+		} else {
+			c.consecutiveFailures++
+		}
+	}
+	t.poll(name, c)
+	t.mu.Unlock()
+
+	t.notifier.deliver()
+}
+
+func (t *hystrixCircuitTracker) state(name string) CircuitState {
+	t.mu.Lock()
+	c := t.circuit(name)
+	t.poll(name, c)
+	state := c.state
+	t.mu.Unlock()
+
+	t.notifier.deliver()
+	return state
+}
+
+func (t *hystrixCircuitTracker) snapshots() map[string]CircuitSnapshot {
+	t.mu.Lock()
+	now := time.Now()
+	snapshots := make(map[string]CircuitSnapshot, len(t.circuits))
+	for name, c := range t.circuits {
+		t.poll(name, c)
+		successes, failures := c.counts.sum(now, hystrixCircuitWindow)
+		snapshots[name] = newCircuitSnapshot(c.state, successes, failures, c.consecutiveFailures)
+	}
+	t.mu.Unlock()
+
+	t.notifier.deliver()
+	return snapshots
+}
+
+// poll updates the state from hystrix and queues the state change, must be called with the lock held
+func (t *hystrixCircuitTracker) poll(name string, c *hystrixCircuit) {
+	state := CircuitClosed
+	if cb, _, err := hystrix.GetCircuit(name); err == nil && cb.IsOpen() {
+		state = CircuitOpen
+	}
+	if state == c.state {
+		return
+	}
+
+	if state == CircuitClosed { // hystrix resets its metrics when the circuit is closed
+		c.counts = circuitCounts{}
+		c.consecutiveFailures = 0
+	}
+	t.notifier.enqueue(name, c.state, state)
+	c.state = state
+}
+
+func (t *hystrixCircuitTracker) circuit(name string) *hystrixCircuit {
+	c, ok := t.circuits[name]
+	if !ok {
+		c = &hystrixCircuit{}
+		t.circuits[name] = c
+	}
+	return c
+}
+
+// SlidingWindowCircuitBreakerOptions options for the SlidingWindowCircuitBreaker
+type SlidingWindowCircuitBreakerOptions struct {
+	// Window is the rolling window used to measure the error percentage.
+	// Default is 10 seconds
+	Window time.Duration
+
+	// RequestVolumeThreshold is the minimum number of requests in the window needed before a circuit can be tripped by ErrorPercentThreshold.
+	// Default is 20
+	RequestVolumeThreshold int
+
+	// ErrorPercentThreshold causes circuits to open once the errors in the window exceed this percent of requests.
+	// Default is 50
+	ErrorPercentThreshold int
+
+	// ConsecutiveFailures causes circuits to open once this number of calls fail in a row.
+	// When zero, circuits are only tripped by ErrorPercentThreshold
+	ConsecutiveFailures int
+
+	// SleepWindow is how long to wait after a circuit opens before testing for recovery.
+	// Default is 5 seconds
+	SleepWindow time.Duration
+
+	// HalfOpenMaxRequests is how many calls are allowed to test the recovery when the circuit is half-open.
+	// Default is 1
+	HalfOpenMaxRequests int
+
+	// MaxConcurrentRequests is how many calls of the same circuit can run at the same time.
+	// When zero, there is no limit
+	MaxConcurrentRequests int
+
+	// Clock returns the current time, default is time.Now
+	Clock func() time.Time
+
+	// UseMetrics flag if the circuit breaker will record circuit_breaker.state_changes and circuit_breaker.rejections metrics
+	UseMetrics bool
+
+	// OnStateChange is called after the circuit of the name changes its state.
+	// The calls are serialized and in the order of the state changes, but they may run on the goroutine of another call
+	// of the circuit breaker. OnStateChange may call the circuit breaker, the state changes it causes are delivered after it returns.
+	OnStateChange func(name string, from, to CircuitState)
+}
+
+// CircuitSnapshot state and counts of a circuit over the window
+type CircuitSnapshot struct {
+	State               CircuitState
+	Successes           int
+	Failures            int
+	ConsecutiveFailures int
+	ErrorPercentage     float64
+}
+
+func newCircuitSnapshot(state CircuitState, successes, failures, consecutiveFailures int) CircuitSnapshot {
+	snapshot := CircuitSnapshot{
+		State:               state,
+		Successes:           successes,
+		Failures:            failures,
+		ConsecutiveFailures: consecutiveFailures,
+	}
+	if total := successes + failures; total > 0 {
+		snapshot.ErrorPercentage = float64(failures) * 100 / float64(total)
+	}
+	return snapshot
+}
+
+var defaultSlidingWindowCircuitBreakerOptions = &SlidingWindowCircuitBreakerOptions{
+	Window:                 10 * time.Second,
+	RequestVolumeThreshold: 20,
+	ErrorPercentThreshold:  50,
+	SleepWindow:            5 * time.Second,
+	HalfOpenMaxRequests:    1,
+	Clock:                  time.Now,
+}
+
+// SlidingWindowCircuitBreaker dependency-free CircuitBreaker, the circuit of each name is tripped by
+// consecutive failures or by the error percentage over a sliding window
+type SlidingWindowCircuitBreaker struct {
+	options *SlidingWindowCircuitBreakerOptions
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+	notifier circuitStateNotifier
+}
+
+type circuitBucket struct {
+	start     time.Time
+	successes int
+	failures  int
+}
+
+// circuitCounts successes and failures of a circuit over a sliding window of circuitBreakerBucketCount buckets
+type circuitCounts struct {
+	buckets [circuitBreakerBucketCount]circuitBucket
+}
+
+// add counts the call in the bucket of now
+func (c *circuitCounts) add(now time.Time, window time.Duration, success bool) {
+	bucketSize := max(window/circuitBreakerBucketCount, time.Nanosecond)
+	start := now.Truncate(bucketSize)
+	bucket := &c.buckets[(start.UnixNano()/int64(bucketSize))%circuitBreakerBucketCount]
+	if !bucket.start.Equal(start) { // the bucket is from the previous window, reuse it
+		*bucket = circuitBucket{start: start}
+	}
+
+	if success {
+		bucket.successes++
+	} else {
+		bucket.failures++
+	}
+}
+
+// sum returns the successes and the failures in the window
+func (c *circuitCounts) sum(now time.Time, window time.Duration) (successes, failures int) {
+	for _, bucket := range c.buckets {
+		if now.Sub(bucket.start) < window {
+			successes += bucket.successes
+			failures += bucket.failures
+		}
+	}
+	return successes, failures
+}
+
+type circuitStateChange struct {
+	name     string
+	from, to CircuitState
+	callback func(name string, from, to CircuitState)
+}
+
+// circuitStateNotifier records the state changes and delivers them to the callback one at a time and in order.
+// The changes are queued with the circuit breaker lock held, so the queue has the order of the changes,
+// then delivered without the lock so the callback may call the circuit breaker.
+type circuitStateNotifier struct {
+	metrics *circuitBreakerMetrics
+
+	mu       sync.Mutex
+	callback func(name string, from, to CircuitState)
+	queue    []circuitStateChange
+
+	// delivering is held by the goroutine delivering the queue
+	delivering sync.Mutex
+}
+
+func (n *circuitStateNotifier) setCallback(fn func(name string, from, to CircuitState)) {
+	n.mu.Lock()
+	n.callback = fn
+	n.mu.Unlock()
+}
+
+// enqueue queues the state change, must be called with the circuit breaker lock held
+func (n *circuitStateNotifier) enqueue(name string, from, to CircuitState) {
+	if from == to {
+		return
+	}
+	n.metrics.recordStateChange(name, to)
+
+	n.mu.Lock()
+	if n.callback != nil {
+		n.queue = append(n.queue, circuitStateChange{name: name, from: from, to: to, callback: n.callback})
+	}
+	n.mu.Unlock()
+}
+
+// deliver calls the callback for the queued state changes, must be called without the circuit breaker lock held.
+// When another goroutine is already delivering, it delivers the queued changes too.
+func (n *circuitStateNotifier) deliver() {
+	for {
+		if !n.delivering.TryLock() {
+			return
+		}
+		for change, ok := n.dequeue(); ok; change, ok = n.dequeue() {
+			change.callback(change.name, change.from, change.to)
+		}
+		n.delivering.Unlock()
+
+		// a change queued after the last dequeue may be skipped by its goroutine while the queue was delivered
+		n.mu.Lock()
+		empty := len(n.queue) == 0
+		n.mu.Unlock()
+		if empty {
+			return
+		}
+	}
+}
+
+func (n *circuitStateNotifier) dequeue() (circuitStateChange, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if len(n.queue) == 0 {
+		return circuitStateChange{}, false
+	}
+	change := n.queue[0]
+	n.queue = n.queue[1:]
+	return change, true
+}
+
+// circuit state of a single name, guarded by the SlidingWindowCircuitBreaker lock
+type circuit struct {
+	state               CircuitState
+	openedAt            time.Time
+	consecutiveFailures int
+	halfOpenRequests    int
+	running             int
+	counts              circuitCounts
+}
+
+// NewSlidingWindowCircuitBreaker :nodoc:
+func NewSlidingWindowCircuitBreaker(opt *SlidingWindowCircuitBreakerOptions) (*SlidingWindowCircuitBreaker, error) {
+	options := applySlidingWindowCircuitBreakerOptions(opt)
+	if err := options.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid sliding window circuit breaker options: %w", err)
+	}
+
+	b := &SlidingWindowCircuitBreaker{
+		options:  options,
+		circuits: map[string]*circuit{},
+		notifier: circuitStateNotifier{metrics: newCircuitBreakerMetrics(options.UseMetrics)},
+	}
+	b.notifier.setCallback(options.OnStateChange)
+	return b, nil
+}
+
+// CircuitStates returns the snapshot of every circuit, keyed by the circuit name
+func (b *SlidingWindowCircuitBreaker) CircuitStates() map[string]CircuitSnapshot {
+	b.mu.Lock()
+	snapshots := make(map[string]CircuitSnapshot, len(b.circuits))
+	for name, c := range b.circuits {
+		b.refreshState(name, c)
+		successes, failures := c.counts.sum(b.options.Clock(), b.options.Window)
+		snapshots[name] = newCircuitSnapshot(c.state, successes, failures, c.consecutiveFailures)
+	}
+	b.mu.Unlock()
+
+	b.notifier.deliver()
+	return snapshots
+}
+
+// Execute runs fn when the circuit of the name allows it
+func (b *SlidingWindowCircuitBreaker) Execute(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := b.allow(name); err != nil {
+		b.notifier.metrics.recordRejection(ctx, name, err)
+		return err
+	}
+
+	success := false
+	// a panicking fn is recorded as a failure, and its running slot is released
+	defer func() {
+		b.record(name, success)
+	}()
+
+	err := fn(ctx)
+	success = err == nil
+	return err
+}
+
+// State returns the current state of the circuit of the name
+func (b *SlidingWindowCircuitBreaker) State(name string) CircuitState {
+	b.mu.Lock()
+	c := b.circuit(name)
+	b.refreshState(name, c)
+	state := c.state
+	b.mu.Unlock()
+
+	b.notifier.deliver()
+	return state
+}
+
+func (b *SlidingWindowCircuitBreaker) allow(name string) error {
+	b.mu.Lock()
+	defer b.notifier.deliver() // deferred before the unlock, so it's called after the unlock
+	defer b.mu.Unlock()
+
+	c := b.circuit(name)
+	b.refreshState(name, c)
+
+	switch {
+	case c.state == CircuitOpen:
+		return ErrCircuitOpen
+	case c.state == CircuitHalfOpen && c.halfOpenRequests >= b.options.HalfOpenMaxRequests:
+		return ErrCircuitOpen
+	case b.options.MaxConcurrentRequests > 0 && c.running >= b.options.MaxConcurrentRequests:
+		return ErrMaxConcurrency
+	}
+
+	if c.state == CircuitHalfOpen {
+		c.halfOpenRequests++
+	}
+	c.running++
+	return nil
+}
+
+func (b *SlidingWindowCircuitBreaker) record(name string, success bool) {
+	b.mu.Lock()
+	defer b.notifier.deliver()
+	defer b.mu.Unlock()
+
+	c := b.circuit(name)
+	c.running--
+
+	c.counts.add(b.options.Clock(), b.options.Window, success)
+	if success {
+		c.consecutiveFailures = 0
+	} else {
+		c.consecutiveFailures++
+	}
+
+	switch {
+	case c.state == CircuitHalfOpen && success:
+		b.setState(name, c, CircuitClosed)
+	case c.state == CircuitHalfOpen, c.state == CircuitClosed && b.shouldTrip(c):
+		b.setState(name, c, CircuitOpen)
+	}
+}
+
+// refreshState moves an open circuit to half-open once the sleep window is passed
+func (b *SlidingWindowCircuitBreaker) refreshState(name string, c *circuit) {
+	if c.state == CircuitOpen && b.options.Clock().Sub(c.openedAt) >= b.options.SleepWindow {
+		b.setState(name, c, CircuitHalfOpen)
+	}
+}
+
+// setState changes the state and queues the state change, must be called with the lock held
+func (b *SlidingWindowCircuitBreaker) setState(name string, c *circuit, state CircuitState) {
+	b.notifier.enqueue(name, c.state, state)
+	c.state = state
+	c.halfOpenRequests = 0
+	switch state {
+	case CircuitOpen:
+		c.openedAt = b.options.Clock()
+	case CircuitClosed:
+		c.consecutiveFailures = 0
+		c.counts = circuitCounts{}
+	case CircuitHalfOpen:
+	}
+}
+
+func (b *SlidingWindowCircuitBreaker) shouldTrip(c *circuit) bool {
+	if b.options.ConsecutiveFailures > 0 && c.consecutiveFailures >= b.options.ConsecutiveFailures {
+		return true
+	}
+
+	successes, failures := c.counts.sum(b.options.Clock(), b.options.Window)
+	total := successes + failures
+	return total >= b.options.RequestVolumeThreshold && failures*100 >= b.options.ErrorPercentThreshold*total
+}
+
+func (b *SlidingWindowCircuitBreaker) circuit(name string) *circuit {
+	c, ok := b.circuits[name]
+	if !ok {
+		c = &circuit{}
+		b.circuits[name] = c
+	}
+	return c
+}
+
+// Validate returns the joined errors of every invalid field
+func (o *SlidingWindowCircuitBreakerOptions) Validate() error {
+	v := &optionsValidator{}
+	v.check(o.Window > 0, "Window", "must be positive")
+	v.check(o.RequestVolumeThreshold >= 0, "RequestVolumeThreshold", "must not be negative")
+	v.check(o.ErrorPercentThreshold >= 0 && o.ErrorPercentThreshold <= 100, "ErrorPercentThreshold", "must be between 0 and 100")
+	v.check(o.ConsecutiveFailures >= 0, "ConsecutiveFailures", "must not be negative")
+	v.check(o.SleepWindow >= 0, "SleepWindow", "must not be negative")
+	v.check(o.HalfOpenMaxRequests >= 0, "HalfOpenMaxRequests", "must not be negative")
+	v.check(o.MaxConcurrentRequests >= 0, "MaxConcurrentRequests", "must not be negative")
+	return v.err()
+}
+
+func applySlidingWindowCircuitBreakerOptions(opt *SlidingWindowCircuitBreakerOptions) *SlidingWindowCircuitBreakerOptions {
+	if opt == nil {
+		return defaultSlidingWindowCircuitBreakerOptions
+	}
+
+	// if error occurs, also return options from input
+	_ = mergo.Merge(opt, *defaultSlidingWindowCircuitBreakerOptions)
+	return opt
+}
@@ -0,0 +1,237 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/afex/hystrix-go/hystrix"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSlidingWindowCircuitBreaker(t *testing.T, opt *SlidingWindowCircuitBreakerOptions) *SlidingWindowCircuitBreaker {
+	breaker, err := NewSlidingWindowCircuitBreaker(opt)
+	require.NoError(t, err)
+	return breaker
+}
+
+func TestSlidingWindowCircuitBreaker(t *testing.T) {
+	errFailed := errors.New("failed")
+	succeed := func(_ context.Context) error { return nil }
+	fail := func(_ context.Context) error { return errFailed }
+
+	newBreaker := func(opt *SlidingWindowCircuitBreakerOptions) (*SlidingWindowCircuitBreaker, *time.Time) {
+		now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		opt.Clock = func() time.Time { return now }
+		return newTestSlidingWindowCircuitBreaker(t, opt), &now
+	}
+
+	t.Run("trip by consecutive failures", func(t *testing.T) {
+		breaker, _ := newBreaker(&SlidingWindowCircuitBreakerOptions{ConsecutiveFailures: 3})
+
+		for range 2 {
+			assert.ErrorIs(t, breaker.Execute(context.Background(), "cmd", fail), errFailed)
+		}
+		assert.NoError(t, breaker.Execute(context.Background(), "cmd", succeed)) // reset the consecutive failures
+		for range 2 {
+			assert.ErrorIs(t, breaker.Execute(context.Background(), "cmd", fail), errFailed)
+		}
+		assert.Equal(t, CircuitClosed, breaker.State("cmd"))
+
+		assert.ErrorIs(t, breaker.Execute(context.Background(), "cmd", fail), errFailed)
+		assert.Equal(t, CircuitOpen, breaker.State("cmd"))
+		assert.ErrorIs(t, breaker.Execute(context.Background(), "cmd", succeed), ErrCircuitOpen)
+		assert.Equal(t, CircuitClosed, breaker.State("other-cmd"))
+	})
+
+	t.Run("trip by error percentage", func(t *testing.T) {
+		breaker, now := newBreaker(&SlidingWindowCircuitBreakerOptions{
+			Window:                 10 * time.Second,
+			RequestVolumeThreshold: 4,
+			ErrorPercentThreshold:  50,
+		})
+
+		_ = breaker.Execute(context.Background(), "cmd", fail)
+		_ = breaker.Execute(context.Background(), "cmd", succeed)
+		_ = breaker.Execute(context.Background(), "cmd", succeed)
+		assert.Equal(t, CircuitClosed, breaker.State("cmd"))
+
+		// the old requests are out of the window
+		*now = now.Add(10 * time.Second)
+		_ = breaker.Execute(context.Background(), "cmd", fail)
+		assert.Equal(t, CircuitClosed, breaker.State("cmd"))
+
+		_ = breaker.Execute(context.Background(), "cmd", succeed)
+		_ = breaker.Execute(context.Background(), "cmd", succeed)
+		_ = breaker.Execute(context.Background(), "cmd", fail)
+		assert.Equal(t, CircuitOpen, breaker.State("cmd"))
+	})
+
+	t.Run("half-open", func(t *testing.T) {
+		breaker, now := newBreaker(&SlidingWindowCircuitBreakerOptions{
+			ConsecutiveFailures: 1,
+			SleepWindow:         5 * time.Second,
+		})
+
+		_ = breaker.Execute(context.Background(), "cmd", fail)
+		assert.Equal(t, CircuitOpen, breaker.State("cmd"))
+
+		*now = now.Add(5 * time.Second)
+		assert.Equal(t, CircuitHalfOpen, breaker.State("cmd"))
+
+		// failed test reopens the circuit
+		_ = breaker.Execute(context.Background(), "cmd", fail)
+		assert.Equal(t, CircuitOpen, breaker.State("cmd"))
+
+		*now = now.Add(5 * time.Second)
+		err := breaker.Execute(context.Background(), "cmd", func(ctx context.Context) error {
+			// only a single test is allowed at the same time
+			assert.ErrorIs(t, breaker.Execute(ctx, "cmd", succeed), ErrCircuitOpen)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, CircuitClosed, breaker.State("cmd"))
+	})
+
+	t.Run("max concurrency", func(t *testing.T) {
+		breaker, _ := newBreaker(&SlidingWindowCircuitBreakerOptions{MaxConcurrentRequests: 1})
+
+		err := breaker.Execute(context.Background(), "cmd", func(ctx context.Context) error {
+			assert.ErrorIs(t, breaker.Execute(ctx, "cmd", succeed), ErrMaxConcurrency)
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("panic releases the running slot", func(t *testing.T) {
+		breaker, _ := newBreaker(&SlidingWindowCircuitBreakerOptions{MaxConcurrentRequests: 1})
+
+		assert.Panics(t, func() {
+			_ = breaker.Execute(context.Background(), "cmd", func(_ context.Context) error { panic("boom") })
+		})
+		assert.NoError(t, breaker.Execute(context.Background(), "cmd", succeed))
+		assert.Equal(t, 1, breaker.CircuitStates()["cmd"].Failures)
+	})
+}
+
+func TestSlidingWindowCircuitBreaker_observability(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	type stateChange struct {
+		name     string
+		from, to CircuitState
+	}
+	var changes []stateChange
+
+	breaker := newTestSlidingWindowCircuitBreaker(t, &SlidingWindowCircuitBreakerOptions{
+		ConsecutiveFailures: 2,
+		SleepWindow:         time.Second,
+		Clock:               func() time.Time { return now },
+		OnStateChange: func(name string, from, to CircuitState) {
+			changes = append(changes, stateChange{name: name, from: from, to: to})
+		},
+	})
+
+	fail := func(_ context.Context) error { return errors.New("failed") }
+	_ = breaker.Execute(context.Background(), "cmd", func(_ context.Context) error { return nil })
+	_ = breaker.Execute(context.Background(), "cmd", fail)
+	_ = breaker.Execute(context.Background(), "cmd", fail)
+
+	snapshot := breaker.CircuitStates()["cmd"]
+	assert.Equal(t, CircuitOpen, snapshot.State)
+	assert.Equal(t, 1, snapshot.Successes)
+	assert.Equal(t, 2, snapshot.Failures)
+	assert.Equal(t, 2, snapshot.ConsecutiveFailures)
+	assert.InDelta(t, 66.67, snapshot.ErrorPercentage, 0.01)
+
+	now = now.Add(time.Second)
+	_ = breaker.Execute(context.Background(), "cmd", func(_ context.Context) error { return nil })
+
+	assert.Equal(t, []stateChange{
+		{name: "cmd", from: CircuitClosed, to: CircuitOpen},
+		{name: "cmd", from: CircuitOpen, to: CircuitHalfOpen},
+		{name: "cmd", from: CircuitHalfOpen, to: CircuitClosed},
+	}, changes)
+}
+
+func Test_recordShortCircuit(t *testing.T) {
+	recorder := setupSpanRecorder(t)
+	ctx, span := newConfig().TracerProvider.Tracer(instrumentationName).Start(context.Background(), "parent")
+
+	recordShortCircuit(ctx, "cmd", errors.New("failed"))
+	recordShortCircuit(ctx, "cmd", ErrCircuitOpen)
+	span.End()
+
+	events := recorder.Ended()[0].Events()
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "circuit breaker short-circuited", events[0].Name)
+	}
+}
+
+func TestSlidingWindowCircuitBreaker_reentrantStateChange(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var breaker *SlidingWindowCircuitBreaker
+	var changes []CircuitState
+	breaker = newTestSlidingWindowCircuitBreaker(t, &SlidingWindowCircuitBreakerOptions{
+		ConsecutiveFailures: 1,
+		SleepWindow:         time.Second,
+		Clock:               func() time.Time { return now },
+		OnStateChange: func(name string, _, to CircuitState) {
+			changes = append(changes, to)
+			if to == CircuitOpen {
+				now = now.Add(time.Second)
+				// the half-open change is delivered after this callback returns
+				assert.Equal(t, CircuitHalfOpen, breaker.State(name))
+				assert.Equal(t, []CircuitState{CircuitOpen}, changes)
+			}
+		},
+	})
+
+	_ = breaker.Execute(context.Background(), "cmd", func(_ context.Context) error { return errors.New("failed") })
+	assert.Equal(t, []CircuitState{CircuitOpen, CircuitHalfOpen}, changes)
+}
+
+func TestHystrixCircuitBreaker(t *testing.T) {
+	// the hystrix circuits are global, a unique name keeps the circuit opened by the previous run out of this one
+	name := fmt.Sprintf("%s-%d", t.Name(), time.Now().UnixNano())
+	hystrix.ConfigureCommand(name, hystrix.CommandConfig{
+		RequestVolumeThreshold: 1,
+		ErrorPercentThreshold:  1,
+		SleepWindow:            5000,
+	})
+
+	var mu sync.Mutex
+	var changes []CircuitState
+	OnHystrixCircuitStateChange(func(changed string, from, to CircuitState) {
+		mu.Lock()
+		defer mu.Unlock()
+		if changed == name {
+			changes = append(changes, from, to)
+		}
+	})
+	t.Cleanup(func() { OnHystrixCircuitStateChange(nil) })
+
+	breaker := NewHystrixCircuitBreaker()
+	errFailed := errors.New("failed")
+	assert.ErrorIs(t, breaker.Execute(context.Background(), name, func(_ context.Context) error { return errFailed }), errFailed)
+
+	// hystrix updates its metrics asynchronously
+	assert.Eventually(t, func() bool { return breaker.State(name) == CircuitOpen }, time.Second, 10*time.Millisecond)
+	err := breaker.Execute(context.Background(), name, func(_ context.Context) error { return nil })
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.ErrorIs(t, err, hystrix.ErrCircuitOpen)
+	assert.EqualError(t, err, hystrix.ErrCircuitOpen.Error())
+
+	snapshot := NewHystrixCircuitBreaker().CircuitStates()[name]
+	assert.Equal(t, CircuitOpen, snapshot.State)
+	assert.Equal(t, 0, snapshot.Successes)
+	assert.Equal(t, 1, snapshot.Failures)
+	assert.Equal(t, float64(100), snapshot.ErrorPercentage)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []CircuitState{CircuitClosed, CircuitOpen}, changes)
+}
@@ -0,0 +1,131 @@
+package connect
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/imdario/mergo"
+	"github.com/jpillora/backoff"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	// cockroachRestartSavepoint is the savepoint name of the CockroachDB client-side transaction retry protocol
+	cockroachRestartSavepoint = "cockroach_restart"
+	// retryableSQLState is the SQLSTATE of the serialization failure, the transaction must be retried by the client
+	retryableSQLState = "40001"
+	// txRetriesKey is the number of retries of a transaction.
+	txRetriesKey = attribute.Key("db.transaction.retries")
+)
+
+// ErrTxRetriesExhausted returned when the transaction still fails with a retryable error after MaxRetries
+var ErrTxRetriesExhausted = errors.New("transaction retries exhausted")
+
+// CockroachTxOptions options for ExecuteTx
+type CockroachTxOptions struct {
+	// MaxRetries maximum retries of the transaction.
+	// Default is 10
+	MaxRetries int
+
+	// RetryInterval initial backoff between retries.
+	// Default is 50 milliseconds
+	RetryInterval time.Duration
+
+	// MaxRetryInterval maximum backoff between retries.
+	// Default is 1 second
+	MaxRetryInterval time.Duration
+
+	// TxOptions isolation level and read only flag of the transaction
+	TxOptions *sql.TxOptions
+}
+
+var defaultCockroachTxOptions = &CockroachTxOptions{
+	MaxRetries:       10,
+	RetryInterval:    50 * time.Millisecond,
+	MaxRetryInterval: 1 * time.Second,
+}
+
+// ExecuteTx runs fn in a transaction and retries it on serialization failures (SQLSTATE 40001)
+// using the cockroach_restart SAVEPOINT protocol, see https://www.cockroachlabs.com/docs/stable/advanced-client-side-transaction-retries.
+// fn may be called many times, so it must not have side effects outside the transaction.
+// The number of retries is recorded on the active span of ctx.
+func ExecuteTx(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error, opt *CockroachTxOptions) (err error) {
+	options := applyCockroachTxOptions(opt)
+
+	tx := db.WithContext(ctx).Begin(options.TxOptions)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback().Error // the transaction is already failed, keep the original error
+		}
+	}()
+
+	if err = tx.Exec("SAVEPOINT " + cockroachRestartSavepoint).Error; err != nil {
+		return err
+	}
+
+	span := trace.SpanFromContext(ctx)
+	retries := 0
+	defer func() {
+		span.SetAttributes(txRetriesKey.Int(retries))
+	}()
+
+	b := &backoff.Backoff{
+		Factor: 2,
+		Jitter: true,
+		Min:    options.RetryInterval,
+		Max:    options.MaxRetryInterval,
+	}
+
+	for {
+		err = fn(tx)
+		if err == nil {
+			err = tx.Exec("RELEASE SAVEPOINT " + cockroachRestartSavepoint).Error
+		}
+		if err == nil {
+			return tx.Commit().Error
+		}
+
+		if !isRetryableTxError(err) {
+			return err
+		}
+		if retries >= options.MaxRetries {
+			return fmt.Errorf("%w after %d retries: %w", ErrTxRetriesExhausted, retries, err)
+		}
+
+		if err = tx.Exec("ROLLBACK TO SAVEPOINT " + cockroachRestartSavepoint).Error; err != nil {
+			return err
+		}
+		retries++
+		span.AddEvent("transaction retry", trace.WithAttributes(txRetriesKey.Int(retries)))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(b.Duration()):
+		}
+	}
+}
+
+// isRetryableTxError checks if the error is a serialization failure, the driver error must implement SQLState like pgconn.PgError
+func isRetryableTxError(err error) bool {
+	var sqlStateErr interface{ SQLState() string }
+	return errors.As(err, &sqlStateErr) && sqlStateErr.SQLState() == retryableSQLState
+}
+
+func applyCockroachTxOptions(opt *CockroachTxOptions) *CockroachTxOptions {
+	if opt == nil {
+		return defaultCockroachTxOptions
+	}
+
+	// if error occurs, also return options from input
+	_ = mergo.Merge(opt, *defaultCockroachTxOptions)
+	return opt
+}
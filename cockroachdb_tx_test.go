@@ -0,0 +1,258 @@
+package connect
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+// sqlStateError is a driver error with SQLSTATE, like pgconn.PgError
+type sqlStateError struct {
+	code string
+}
+
+func (e *sqlStateError) Error() string { return "sqlstate " + e.code }
+
+func (e *sqlStateError) SQLState() string { return e.code }
+
+// fakeTxDriver records the executed statements and fails the statements by the failures func
+type fakeTxDriver struct {
+	mu         sync.Mutex
+	statements []string
+	failures   func(query string) error
+}
+
+func (d *fakeTxDriver) Open(_ string) (driver.Conn, error) { return &fakeTxConn{driver: d}, nil }
+
+func (d *fakeTxDriver) record(query string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.statements = append(d.statements, query)
+	if d.failures == nil {
+		return nil
+	}
+	return d.failures(query)
+}
+
+type fakeTxConn struct {
+	driver *fakeTxDriver
+}
+
+func (c *fakeTxConn) Prepare(_ string) (driver.Stmt, error) {
+	return nil, errors.New("prepare is not supported")
+}
+
+func (c *fakeTxConn) Close() error { return nil }
+
+func (c *fakeTxConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeTxConn) BeginTx(_ context.Context, _ driver.TxOptions) (driver.Tx, error) {
+	return &fakeTx{conn: c}, c.driver.record("BEGIN")
+}
+
+func (c *fakeTxConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if err := c.driver.record(query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeTxConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	return &fakeRows{}, c.driver.record(query)
+}
+
+type fakeTx struct {
+	conn *fakeTxConn
+}
+
+func (t *fakeTx) Commit() error { return t.conn.driver.record("COMMIT") }
+
+func (t *fakeTx) Rollback() error { return t.conn.driver.record("ROLLBACK") }
+
+type fakeRows struct{}
+
+func (r *fakeRows) Columns() []string { return nil }
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(_ []driver.Value) error { return io.EOF }
+
+// fakeTxDriverName the driver registered once for every test, it opens the connections with the fakeTxDriver of the DSN
+const fakeTxDriverName = "fake-tx"
+
+var (
+	fakeTxDrivers   sync.Map // DSN -> *fakeTxDriver
+	fakeTxDriverIDs atomic.Int64
+)
+
+func init() {
+	sql.Register(fakeTxDriverName, fakeTxDriverRouter{})
+}
+
+// fakeTxDriverRouter opens the connections with the fakeTxDriver registered by registerFakeTxDriver
+type fakeTxDriverRouter struct{}
+
+func (fakeTxDriverRouter) Open(dsn string) (driver.Conn, error) {
+	d, ok := fakeTxDrivers.Load(dsn)
+	if !ok {
+		return nil, fmt.Errorf("unknown fake DSN %q", dsn)
+	}
+	return d.(*fakeTxDriver).Open(dsn)
+}
+
+// registerFakeTxDriver returns the DSN of the fakeTxDriverName driver opening the connections with d
+func registerFakeTxDriver(t *testing.T, d *fakeTxDriver) string {
+	dsn := fmt.Sprintf("fake-%d", fakeTxDriverIDs.Add(1))
+	fakeTxDrivers.Store(dsn, d)
+	t.Cleanup(func() { fakeTxDrivers.Delete(dsn) })
+	return dsn
+}
+
+// newFakeTxDB returns a gorm DB using the fake driver
+func newFakeTxDB(t *testing.T, failures func(query string) error) (*gorm.DB, *fakeTxDriver) {
+	d := &fakeTxDriver{failures: failures}
+	dsn := registerFakeTxDriver(t, d)
+
+	db, err := gorm.Open(postgres.New(postgres.Config{DriverName: fakeTxDriverName, DSN: dsn}), &gorm.Config{
+		Logger: gormLogger.Discard,
+	})
+	require.NoError(t, err)
+
+	d.statements = nil // ignore the statements of the initialization
+	return db, d
+}
+
+func TestExecuteTx(t *testing.T) {
+	retryOptions := func() *CockroachTxOptions {
+		return &CockroachTxOptions{MaxRetries: 3, RetryInterval: time.Millisecond, MaxRetryInterval: time.Millisecond}
+	}
+
+	t.Run("commit without retry", func(t *testing.T) {
+		db, d := newFakeTxDB(t, nil)
+
+		err := ExecuteTx(context.Background(), db, func(tx *gorm.DB) error {
+			return tx.Exec("UPDATE accounts SET balance = 1").Error
+		}, retryOptions())
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{
+			"BEGIN",
+			"SAVEPOINT cockroach_restart",
+			"UPDATE accounts SET balance = 1",
+			"RELEASE SAVEPOINT cockroach_restart",
+			"COMMIT",
+		}, d.statements)
+	})
+
+	t.Run("retry on serialization failure", func(t *testing.T) {
+		failures := 2
+		db, d := newFakeTxDB(t, func(query string) error {
+			if strings.HasPrefix(query, "UPDATE") && failures > 0 {
+				failures--
+				return &sqlStateError{code: retryableSQLState}
+			}
+			return nil
+		})
+
+		recorder := setupSpanRecorder(t)
+		ctx, span := otel.Tracer("test").Start(context.Background(), "tx")
+		calls := 0
+		err := ExecuteTx(ctx, db, func(tx *gorm.DB) error {
+			calls++
+			return tx.Exec("UPDATE accounts SET balance = 1").Error
+		}, retryOptions())
+		span.End()
+		require.NoError(t, err)
+
+		assert.Equal(t, 3, calls)
+		assert.Equal(t, []string{
+			"BEGIN",
+			"SAVEPOINT cockroach_restart",
+			"UPDATE accounts SET balance = 1",
+			"ROLLBACK TO SAVEPOINT cockroach_restart",
+			"UPDATE accounts SET balance = 1",
+			"ROLLBACK TO SAVEPOINT cockroach_restart",
+			"UPDATE accounts SET balance = 1",
+			"RELEASE SAVEPOINT cockroach_restart",
+			"COMMIT",
+		}, d.statements)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		var retries int64
+		for _, attr := range spans[0].Attributes() {
+			if attr.Key == txRetriesKey {
+				retries = attr.Value.AsInt64()
+			}
+		}
+		assert.EqualValues(t, 2, retries)
+	})
+
+	t.Run("retry when the release fails", func(t *testing.T) {
+		failed := false
+		db, _ := newFakeTxDB(t, func(query string) error {
+			if strings.HasPrefix(query, "RELEASE") && !failed {
+				failed = true
+				return &sqlStateError{code: retryableSQLState}
+			}
+			return nil
+		})
+
+		calls := 0
+		err := ExecuteTx(context.Background(), db, func(_ *gorm.DB) error {
+			calls++
+			return nil
+		}, retryOptions())
+		require.NoError(t, err)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("cap the retries", func(t *testing.T) {
+		db, d := newFakeTxDB(t, func(query string) error {
+			if strings.HasPrefix(query, "UPDATE") {
+				return &sqlStateError{code: retryableSQLState}
+			}
+			return nil
+		})
+
+		calls := 0
+		err := ExecuteTx(context.Background(), db, func(tx *gorm.DB) error {
+			calls++
+			return tx.Exec("UPDATE accounts SET balance = 1").Error
+		}, retryOptions())
+		assert.ErrorIs(t, err, ErrTxRetriesExhausted)
+		assert.Equal(t, 4, calls)
+		assert.Equal(t, "ROLLBACK", d.statements[len(d.statements)-1])
+	})
+
+	t.Run("no retry on other errors", func(t *testing.T) {
+		db, d := newFakeTxDB(t, nil)
+
+		calls := 0
+		fnErr := &sqlStateError{code: "23505"}
+		err := ExecuteTx(context.Background(), db, func(_ *gorm.DB) error {
+			calls++
+			return fnErr
+		}, retryOptions())
+		assert.ErrorIs(t, err, fnErr)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, []string{"BEGIN", "SAVEPOINT cockroach_restart", "ROLLBACK"}, d.statements)
+	})
+}
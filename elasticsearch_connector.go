@@ -2,6 +2,7 @@ package connect
 
 import (
 	"crypto/tls"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -42,6 +43,9 @@ type ESTraceLogger struct{}
 // NewElasticsearchClient :nodoc:
 func NewElasticsearchClient(url string, httpClient *http.Client, opt *ElasticsearchConnectionOptions) (*elastic.Client, error) {
 	options := applyElasticsearchConnectionOptions(opt)
+	if err := options.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid elasticsearch connection options: %w", err)
+	}
 
 	httpTranspost := &http.Transport{
 		TLSHandshakeTimeout: options.TLSHandshakeTimeout,
@@ -82,6 +86,15 @@ func (*ESErrorLogger) Printf(format string, values ...interface{}) {
 	log.WithFields(log.Fields{"type": "elasticsearch-log"}).Errorf(format, values...)
 }
 
+// Validate returns the joined errors of every invalid field
+func (o *ElasticsearchConnectionOptions) Validate() error {
+	v := &optionsValidator{}
+	v.check(o.TLSHandshakeTimeout >= 0, "TLSHandshakeTimeout", "must not be negative")
+	v.check(o.MaxIdleConnections >= 0, "MaxIdleConnections", "must not be negative")
+	v.check(o.MaxConnsPerHost >= 0, "MaxConnsPerHost", "must not be negative")
+	return v.err()
+}
+
 func applyElasticsearchConnectionOptions(opt *ElasticsearchConnectionOptions) *ElasticsearchConnectionOptions {
 	if opt != nil {
 		return opt
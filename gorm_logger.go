@@ -0,0 +1,229 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+// redactedParameter replaces the values of the sensitive columns
+const redactedParameter = "[REDACTED]"
+
+var (
+	defaultSlowThreshold    = 200 * time.Millisecond
+	defaultSensitiveColumns = []string{"password", "secret", "token"}
+
+	sqlPlaceholderRegexp = regexp.MustCompile(`\$\d+|\?`)
+	// explainedPlaceholderRegexp matches the numbered placeholder left by gorm when its parameter is not given, e.g. $1$
+	explainedPlaceholderRegexp = regexp.MustCompile(`\$(\d+)\$`)
+	// insertColumnsRegexp matches the column list of an INSERT, e.g. INSERT INTO users (name,password) VALUES
+	insertColumnsRegexp = regexp.MustCompile(`(?is)^\s*INSERT\s+INTO\s+\S+\s*\(([^)]*)\)\s*VALUES`)
+	// comparedColumnRegexp matches the column compared with the following placeholder, e.g. name = or id IN (?,
+	comparedColumnRegexp = regexp.MustCompile("(?i)(\\w+)[`\"\\]]?\\s*(?:=|<>|!=|<=|>=|<|>|(?:NOT\\s+)?(?:I?LIKE|IN\\s*\\([^()]*))\\s*$")
+)
+
+// GormCustomLogger override gorm logger
+type GormCustomLogger struct {
+	gormLogger.Config
+
+	// LogParameters flag if the bound parameters are logged in the SQL, the values of the SensitiveColumns are redacted
+	LogParameters bool
+
+	// SensitiveColumns the values of the columns containing any of them, case insensitive, are redacted
+	SensitiveColumns []string
+}
+
+// NewGormCustomLogger :nodoc:
+func NewGormCustomLogger() *GormCustomLogger {
+	return &GormCustomLogger{
+		Config: gormLogger.Config{
+			LogLevel:      gormLogger.Info,
+			SlowThreshold: defaultSlowThreshold,
+		},
+		SensitiveColumns: defaultSensitiveColumns,
+	}
+}
+
+// newGormLogger creates the logger of the SQL connection options
+func newGormLogger(options *SQLConnectionOptions) *GormCustomLogger {
+	logger := NewGormCustomLogger()
+	logger.LogLevel = gormLogLevel(options.LogLevel)
+	logger.SlowThreshold = max(options.SlowThreshold, 0)
+	logger.LogParameters = options.LogParameters
+	logger.SensitiveColumns = options.SensitiveColumns
+	return logger
+}
+
+// LogMode :nodoc:
+func (g *GormCustomLogger) LogMode(level gormLogger.LogLevel) gormLogger.Interface {
+	g.LogLevel = level
+	return g
+}
+
+// Info :nodoc:
+func (g *GormCustomLogger) Info(ctx context.Context, message string, values ...interface{}) {
+	if g.LogLevel >= gormLogger.Info {
+		log.WithFields(contextLogFields(ctx)).WithFields(log.Fields{"data": values}).Info(message)
+	}
+}
+
+// Warn :nodoc:
+func (g *GormCustomLogger) Warn(ctx context.Context, message string, values ...interface{}) {
+	if g.LogLevel >= gormLogger.Warn {
+		log.WithFields(contextLogFields(ctx)).WithFields(log.Fields{"data": values}).Warn(message)
+	}
+}
+
+// Error :nodoc:
+func (g *GormCustomLogger) Error(ctx context.Context, message string, values ...interface{}) {
+	if g.LogLevel >= gormLogger.Error {
+		log.WithFields(contextLogFields(ctx)).WithFields(log.Fields{"data": values}).Error(message)
+	}
+}
+
+// Trace logs the failed, slow or every statement by the log level, with the caller and the trace of the ctx
+func (g *GormCustomLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+	failed := err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && g.LogLevel >= gormLogger.Error
+	slow := g.SlowThreshold > 0 && elapsed > g.SlowThreshold && g.LogLevel >= gormLogger.Warn
+	if !failed && !slow && g.LogLevel < gormLogger.Info {
+		return
+	}
+
+	sql, rows := fc()
+	if !g.LogParameters {
+		sql = explainedPlaceholderRegexp.ReplaceAllString(sql, "$$$1")
+	}
+	logger := log.WithFields(contextLogFields(ctx)).WithFields(log.Fields{
+		"took":   elapsed,
+		"sql":    sql,
+		"caller": gormCaller(),
+	})
+	if rows >= 0 {
+		logger = logger.WithField("rows", rows)
+	} else {
+		logger = logger.WithField("rows", "-")
+	}
+
+	switch {
+	case failed:
+		logger.Error(err)
+	case slow:
+		logger.Warn(fmt.Sprintf("SLOW SQL >= %v", g.SlowThreshold))
+	default:
+		logger.Info("SQL")
+	}
+}
+
+// ParamsFilter implements gorm.ParamsFilter, the parameters are left out of the logged SQL unless LogParameters
+// and the values of the SensitiveColumns are redacted
+func (g *GormCustomLogger) ParamsFilter(_ context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if !g.LogParameters {
+		return sql, nil
+	}
+
+	filtered := make([]interface{}, len(params))
+	copy(filtered, params)
+	for i, column := range parameterColumns(sql, len(params)) {
+		if g.isSensitiveColumn(column) {
+			filtered[i] = redactedParameter
+		}
+	}
+	return sql, filtered
+}
+
+func (g *GormCustomLogger) isSensitiveColumn(column string) bool {
+	if column == "" {
+		return false
+	}
+
+	column = strings.ToLower(column)
+	for _, sensitive := range g.SensitiveColumns {
+		if strings.Contains(column, strings.ToLower(sensitive)) {
+			return true
+		}
+	}
+	return false
+}
+
+// parameterColumns returns the column of every parameter of the statement, it's empty when the column is unknown.
+// The columns are taken from the comparisons, e.g. password = ?, and the column list of an INSERT.
+func parameterColumns(sql string, n int) []string {
+	columns := make([]string, n)
+
+	var insertColumns []string
+	insertEnd := -1
+	if loc := insertColumnsRegexp.FindStringSubmatchIndex(sql); loc != nil {
+		for _, column := range strings.Split(sql[loc[2]:loc[3]], ",") {
+			insertColumns = append(insertColumns, strings.Trim(strings.TrimSpace(column), "`\"[]"))
+		}
+		insertEnd = loc[1]
+	}
+
+	values := 0
+	for i, loc := range sqlPlaceholderRegexp.FindAllStringIndex(sql, -1) {
+		index := i
+		if placeholder := sql[loc[0]:loc[1]]; placeholder != "?" {
+			index, _ = strconv.Atoi(placeholder[1:])
+			index-- // $1 is the first parameter
+		}
+		if index < 0 || index >= n {
+			continue
+		}
+
+		// the comparison is close to the placeholder, don't scan the whole statement for every parameter
+		if m := comparedColumnRegexp.FindStringSubmatch(sql[max(loc[0]-256, 0):loc[0]]); m != nil {
+			columns[index] = m[1]
+			continue
+		}
+		if insertEnd >= 0 && loc[0] > insertEnd {
+			columns[index] = insertColumns[values%len(insertColumns)]
+			values++
+		}
+	}
+
+	return columns
+}
+
+// contextLogFields returns the trace_id and span_id of the span in the ctx
+func contextLogFields(ctx context.Context) log.Fields {
+	fields := log.Fields{}
+	if ctx == nil {
+		return fields
+	}
+
+	if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.IsValid() {
+		fields["trace_id"] = spanCtx.TraceID().String()
+		fields["span_id"] = spanCtx.SpanID().String()
+	}
+	return fields
+}
+
+// gormCaller returns the file:line of the first caller outside of gorm and this logger, i.e. the query of the application
+func gormCaller() string {
+	pcs := [16]uintptr{}
+	n := runtime.Callers(3, pcs[:]) // skip runtime.Callers, gormCaller and Trace
+	if n == 0 {
+		return ""
+	}
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.Contains(frame.File, "/gorm.io/") || strings.HasSuffix(frame.File, "_test.go") {
+			return frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
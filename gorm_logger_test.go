@@ -0,0 +1,110 @@
+package connect
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	logTest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+func TestParameterColumns(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{
+			name: "comparisons",
+			sql:  "SELECT * FROM `users` WHERE `users`.`email` = ? AND id IN (?,?) AND name LIKE ? LIMIT ?",
+			want: []string{"email", "id", "id", "name", ""},
+		},
+		{
+			name: "numbered placeholders",
+			sql:  `UPDATE "users" SET "password"=$2 WHERE "id" = $1`,
+			want: []string{"id", "password"},
+		},
+		{
+			name: "insert",
+			sql:  `INSERT INTO "users" ("name","api_token") VALUES ($1,$2),($3,$4) RETURNING "id"`,
+			want: []string{"name", "api_token", "name", "api_token"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parameterColumns(tt.sql, len(tt.want)))
+		})
+	}
+}
+
+func TestGormCustomLogger_Trace(t *testing.T) {
+	hook := logTest.NewGlobal()
+	defer hook.Reset()
+
+	spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{2},
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), spanCtx)
+
+	t.Run("redact the sensitive parameters", func(t *testing.T) {
+		hook.Reset()
+		db, _ := newFakeTxDB(t, nil)
+		db.Logger = newGormLogger(applySQLConnectionOptions(&SQLConnectionOptions{LogParameters: true}))
+
+		require.NoError(t, db.WithContext(ctx).Exec("UPDATE users SET user_password = ? WHERE name = ?", "s3cret", "alice").Error)
+
+		entry := hook.LastEntry()
+		require.NotNil(t, entry)
+		assert.Equal(t, log.InfoLevel, entry.Level)
+		assert.Equal(t, "UPDATE users SET user_password = '[REDACTED]' WHERE name = 'alice'", entry.Data["sql"])
+		assert.Equal(t, spanCtx.TraceID().String(), entry.Data["trace_id"])
+		assert.Equal(t, spanCtx.SpanID().String(), entry.Data["span_id"])
+		assert.Contains(t, entry.Data["caller"], "gorm_logger_test.go")
+		assert.Contains(t, entry.Data, "rows")
+	})
+
+	t.Run("leave out the parameters", func(t *testing.T) {
+		hook.Reset()
+		db, _ := newFakeTxDB(t, nil)
+		db.Logger = newGormLogger(applySQLConnectionOptions(&SQLConnectionOptions{}))
+
+		require.NoError(t, db.Exec("UPDATE users SET name = ?", "alice").Error)
+
+		entry := hook.LastEntry()
+		require.NotNil(t, entry)
+		assert.Equal(t, "UPDATE users SET name = $1", entry.Data["sql"])
+		assert.NotContains(t, entry.Data, "trace_id")
+	})
+
+	t.Run("log the slow statement", func(t *testing.T) {
+		hook.Reset()
+		logger := NewGormCustomLogger()
+		logger.LogLevel = gormLogger.Warn
+		logger.SlowThreshold = time.Millisecond
+
+		logger.Trace(ctx, time.Now().Add(-time.Second), func() (string, int64) { return "SELECT 1", 1 }, nil)
+		logger.Trace(ctx, time.Now(), func() (string, int64) { return "SELECT 2", 1 }, nil)
+
+		require.Len(t, hook.AllEntries(), 1)
+		entry := hook.LastEntry()
+		assert.Equal(t, log.WarnLevel, entry.Level)
+		assert.Equal(t, "SLOW SQL >= 1ms", entry.Message)
+		assert.Equal(t, "SELECT 1", entry.Data["sql"])
+		assert.Equal(t, int64(1), entry.Data["rows"])
+	})
+
+	t.Run("disable the slow statement", func(t *testing.T) {
+		hook.Reset()
+		logger := newGormLogger(applySQLConnectionOptions(&SQLConnectionOptions{LogLevel: "warn", SlowThreshold: -1}))
+
+		logger.Trace(ctx, time.Now().Add(-time.Hour), func() (string, int64) { return "SELECT 1", 1 }, nil)
+		assert.Empty(t, hook.AllEntries())
+	})
+}
@@ -2,6 +2,7 @@ package connect
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"strings"
 	"time"
@@ -12,7 +13,6 @@ import (
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 
-	"github.com/afex/hystrix-go/hystrix"
 	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
 	"github.com/ulule/limiter/v3"
@@ -85,10 +85,32 @@ type GRPCUnaryInterceptorOptions struct {
 	// UseOpenTelemetry flag if the connection will implement open telemetry
 	UseOpenTelemetry bool
 
+	// UseMetrics flag if the interceptors will record rpc.client.duration or rpc.server.duration metrics,
+	// the server interceptors also count the rate limiter decisions
+	UseMetrics bool
+
 	// RateLimiter flag if the connection will implement rate limiter
 	RateLimiter *GRPCRateLimiter
 
 	RecoveryHandlerFunc RecoveryHandlerFunc
+
+	// RetryPolicy decides which errors are retried and the backoff between retries,
+	// when nil only Unavailable is retried with exponential backoff starting from RetryInterval
+	RetryPolicy *GRPCRetryPolicy
+
+	// MethodPolicies overrides Timeout, RetryCount, RetryInterval, RetryPolicy, UseCircuitBreaker and the hedging per method on the client interceptors
+	MethodPolicies GRPCMethodPolicies
+
+	// CircuitBreaker used when UseCircuitBreaker is true, the method name is used as the circuit name.
+	// Default is HystrixCircuitBreaker
+	CircuitBreaker CircuitBreaker
+
+	// Hedging sends hedged attempts for the idempotent methods on the unary client interceptor, when nil hedging is disabled
+	Hedging *GRPCHedgingPolicy
+
+	// idempotent and fallback are set from the method policy
+	idempotent bool
+	fallback   GRPCFallbackFunc
 }
 
 // GRPCRateLimiter wrapper for the gRPC rate limiter
@@ -120,10 +142,34 @@ var defaultGRPCUnaryInterceptorOptions = &GRPCUnaryInterceptorOptions{
 	},
 }
 
-// UnaryClientInterceptor wrapper with circuit breaker, retry, timeout, open telemetry, and metadata logging
+// NewUnaryClientInterceptor creates the UnaryClientInterceptor, returns an error when the options are invalid
+func NewUnaryClientInterceptor(opts *GRPCUnaryInterceptorOptions) (grpc.UnaryClientInterceptor, error) {
+	o := applyGRPCUnaryInterceptorOptions(opts)
+	if err := o.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid gRPC client interceptor options: %w", err)
+	}
+	return unaryClientInterceptor(o), nil
+}
+
+// UnaryClientInterceptor wrapper with circuit breaker, retry, timeout, open telemetry, and metadata logging.
+// The invalid options are only logged, use NewUnaryClientInterceptor to get the error.
 func UnaryClientInterceptor(opts *GRPCUnaryInterceptorOptions) grpc.UnaryClientInterceptor {
 	o := applyGRPCUnaryInterceptorOptions(opts)
-	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if err := o.Validate(); err != nil {
+		logrus.Error("invalid gRPC client interceptor options: ", err)
+	}
+	return unaryClientInterceptor(o)
+}
+
+func unaryClientInterceptor(o *GRPCUnaryInterceptorOptions) grpc.UnaryClientInterceptor {
+	metrics := newRPCMetrics(o.UseMetrics, "rpc.client.duration")
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) (err error) {
+		begin := time.Now()
+		defer func() {
+			metrics.record(ctx, method, begin, err)
+		}()
+
+		o := o.forMethod(method)
 		ctx, cancel := context.WithTimeout(ctx, o.Timeout)
 		defer cancel()
 
@@ -136,8 +182,8 @@ func UnaryClientInterceptor(opts *GRPCUnaryInterceptorOptions) grpc.UnaryClientI
 		if o.UseCircuitBreaker {
 			success := make(chan bool, 1)
 			ignoredError := make(chan error, 1)
-			errC := hystrix.GoC(ctx, method, func(ctx context.Context) error {
-				err := o.retryableInvoke(ctx, method, req, reply, cc, invoker, opts...)
+			err = o.circuitBreaker().Execute(ctx, method, func(ctx context.Context) error {
+				err := o.invoke(ctx, method, req, reply, cc, invoker, opts...)
 
 				switch status.Code(err) { // nolint: exhaustive
 				case codes.OK:
@@ -152,22 +198,71 @@ func UnaryClientInterceptor(opts *GRPCUnaryInterceptorOptions) grpc.UnaryClientI
 					ignoredError <- err
 					return nil
 				}
-			}, nil)
+			})
+			if err != nil {
+				recordShortCircuit(ctx, method, err)
+				if o.fallback != nil && isShortCircuited(err) {
+					return o.fallback(ctx, method, req, reply, err)
+				}
+				logrus.Warnf("failed %s", err)
+				return err
+			}
 
 			select {
 			case out := <-success:
 				logrus.Debugf("success %v", out)
 				return nil
-			case err := <-ignoredError:
-				return err
-			case err := <-errC:
-				logrus.Warnf("failed %s", err)
-				return err
+			default:
+				return <-ignoredError
 			}
 		}
 
-		return o.retryableInvoke(ctx, method, req, reply, cc, invoker, opts...)
+		return o.invoke(ctx, method, req, reply, cc, invoker, opts...)
+	}
+}
+
+func (o *GRPCUnaryInterceptorOptions) circuitBreaker() CircuitBreaker {
+	if o.CircuitBreaker == nil {
+		return NewHystrixCircuitBreaker()
+	}
+	return o.CircuitBreaker
+}
+
+// Validate returns the joined errors of every invalid field
+func (o *GRPCUnaryInterceptorOptions) Validate() error {
+	v := &optionsValidator{}
+	v.check(o.RetryCount >= 0, "RetryCount", "must not be negative")
+	v.check(o.RetryCount == 0 || o.RetryInterval > 0, "RetryInterval", "must be positive when RetryCount is set")
+	v.check(o.Timeout >= 0, "Timeout", "must not be negative")
+	if o.RateLimiter != nil {
+		v.nested("RateLimiter", o.RateLimiter.Validate())
+	}
+	if o.RetryPolicy != nil {
+		v.nested("RetryPolicy", o.RetryPolicy.Validate())
+	}
+	if o.Hedging != nil {
+		v.nested("Hedging", o.Hedging.Validate())
+	}
+	v.nested("MethodPolicies", o.MethodPolicies.Validate())
+	return v.err()
+}
+
+// validateServer returns the joined errors of the invalid fields used by the server interceptors
+func (o *GRPCUnaryInterceptorOptions) validateServer() error {
+	v := &optionsValidator{}
+	v.check(o.Timeout >= 0, "Timeout", "must not be negative")
+	if o.RateLimiter != nil {
+		v.nested("RateLimiter", o.RateLimiter.Validate())
 	}
+	return v.err()
+}
+
+// Validate returns the joined errors of every invalid field
+func (r *GRPCRateLimiter) Validate() error {
+	v := &optionsValidator{}
+	v.check(r.Limit > 0, "Limit", "must be positive")
+	v.check(r.Period > 0, "Period", "must be positive")
+	return v.err()
 }
 
 func applyGRPCUnaryInterceptorOptions(opts *GRPCUnaryInterceptorOptions) *GRPCUnaryInterceptorOptions {
@@ -179,6 +274,15 @@ func applyGRPCUnaryInterceptorOptions(opts *GRPCUnaryInterceptorOptions) *GRPCUn
 	return opts
 }
 
+// applyGRPCServerInterceptorOptions doesn't merge the defaults into opts,
+// e.g. the default RateLimiter would rate limit the server not configuring it
+func applyGRPCServerInterceptorOptions(opts *GRPCUnaryInterceptorOptions) *GRPCUnaryInterceptorOptions {
+	if opts == nil {
+		return defaultGRPCUnaryInterceptorOptions
+	}
+	return opts
+}
+
 // spanInfo returns a span name and all appropriate attributes from the gRPC
 // method and peer address.
 func spanInfo(fullMethod, peerAddress string) (string, []attribute.KeyValue) {
@@ -207,62 +311,103 @@ func peerAttr(addr string) []attribute.KeyValue {
 }
 
 func (o *GRPCUnaryInterceptorOptions) retryableInvoke(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-	return utils.Retry(o.RetryCount, o.RetryInterval, func() (err error) {
-		if !o.UseOpenTelemetry {
-			err = invoker(ctx, method, req, reply, cc, opts...)
+	policy := o.retryPolicy()
+	b := policy.backoff(o.RetryInterval)
+
+	var target string
+	if cc != nil {
+		target = cc.Target()
+	}
+	if policy.Budget != nil {
+		policy.Budget.recordRequest(target)
+	}
 
-			if status.Code(err) != codes.Unavailable { // stop retrying unless Unavailable
-				return utils.NewRetryStopper(err)
+	for attempt := 1; ; attempt++ {
+		var trailer metadata.MD
+		err := o.invokeAttempt(ctx, []attribute.KeyValue{attemptKey.Int(attempt)}, method, req, reply, cc, invoker, append(opts[:len(opts):len(opts)], grpc.Trailer(&trailer))...)
+		if err == nil || attempt >= o.RetryCount || !policy.isRetryable(err) {
+			return err
+		}
+
+		if policy.Budget != nil && !policy.Budget.tryRetry(target) {
+			logrus.WithFields(logrus.Fields{
+				"target":  target,
+				"method":  method,
+				"attempt": attempt,
+			}).Warn("retry budget exhausted, stop retrying")
+			trace.SpanFromContext(ctx).AddEvent("retry budget exhausted", trace.WithAttributes(attemptKey.Int(attempt)))
+			return err
+		}
+
+		delay := b.Duration()
+		if pushback, ok := retryPushback(trailer); ok {
+			if pushback < 0 { // server asks to not retry
+				return err
 			}
+			delay = pushback
+		}
 
+		select {
+		case <-ctx.Done():
 			return err
+		case <-time.After(delay):
 		}
+	}
+}
 
-		requestMetadata, _ := metadata.FromOutgoingContext(ctx)
-		metadataCopy := requestMetadata.Copy()
-		tracer := newConfig().TracerProvider.Tracer(
-			instrumentationName,
-		)
+// invokeAttempt invokes a single attempt, the attrs identify the attempt on its client span
+func (o *GRPCUnaryInterceptorOptions) invokeAttempt(ctx context.Context, attrs []attribute.KeyValue, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if timeout := o.retryPolicy().PerAttemptTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
-		name, attr := spanInfo(method, cc.Target())
+	if !o.UseOpenTelemetry {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
 
-		var span trace.Span
-		ctx, span = tracer.Start(
-			ctx,
-			name,
-			trace.WithSpanKind(trace.SpanKindClient),
-			trace.WithAttributes(attr...),
-		)
-		defer span.End()
+	requestMetadata, _ := metadata.FromOutgoingContext(ctx)
+	metadataCopy := requestMetadata.Copy()
+	tracer := newConfig().TracerProvider.Tracer(
+		instrumentationName,
+	)
 
-		inject(ctx, &metadataCopy)
-		ctx = metadata.NewOutgoingContext(ctx, metadataCopy)
+	name, attr := spanInfo(method, cc.Target())
 
-		messageSent.Event(ctx, defaultMessageID, req)
+	var span trace.Span
+	ctx, span = tracer.Start(
+		ctx,
+		name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attr...),
+		trace.WithAttributes(attrs...),
+	)
+	defer span.End()
 
-		err = invoker(ctx, method, req, reply, cc, opts...)
+	inject(ctx, &metadataCopy)
+	ctx = metadata.NewOutgoingContext(ctx, metadataCopy)
 
-		messageReceived.Event(ctx, defaultMessageID, reply)
+	messageSent.Event(ctx, defaultMessageID, req)
 
-		switch {
-		case span == nil:
-			logrus.WithFields(logrus.Fields{
-				"context": utils.DumpIncomingContext(ctx),
-			}).Error("span is nil")
-		case err != nil:
-			s, _ := status.FromError(err)
-			span.SetStatus(otelcodes.Error, s.Message())
-			span.SetAttributes(statusCodeAttr(s.Code()))
-		default:
-			span.SetAttributes(statusCodeAttr(codes.OK))
-		}
+	err := invoker(ctx, method, req, reply, cc, opts...)
 
-		if status.Code(err) != codes.Unavailable { // stop retrying unless Unavailable
-			return utils.NewRetryStopper(err)
-		}
+	messageReceived.Event(ctx, defaultMessageID, reply)
 
-		return err
-	})
+	switch {
+	case span == nil:
+		logrus.WithFields(logrus.Fields{
+			"context": utils.DumpIncomingContext(ctx),
+		}).Error("span is nil")
+	case err != nil:
+		s, _ := status.FromError(err)
+		span.SetStatus(otelcodes.Error, s.Message())
+		span.SetAttributes(statusCodeAttr(s.Code()))
+	default:
+		span.SetAttributes(statusCodeAttr(codes.OK))
+	}
+
+	return err
 }
 
 // peerFromCtx returns a peer address from a context, if one exists.
@@ -279,28 +424,56 @@ func statusCodeAttr(c codes.Code) attribute.KeyValue {
 	return grpcStatusCodeKey.Int64(int64(c))
 }
 
-// UnaryServerInterceptor wrapper with open telemetry
-//
-//gocognit:ignore
+// NewUnaryServerInterceptor creates the UnaryServerInterceptor, returns an error when the options are invalid
+func NewUnaryServerInterceptor(opts *GRPCUnaryInterceptorOptions, redisClient *redis.Client) (grpc.UnaryServerInterceptor, error) {
+	o := applyGRPCServerInterceptorOptions(opts)
+	if err := o.validateServer(); err != nil {
+		return nil, fmt.Errorf("invalid gRPC server interceptor options: %w", err)
+	}
+	return unaryServerInterceptor(o, redisClient), nil
+}
+
+// UnaryServerInterceptor wrapper with open telemetry, the default options are used when opts is nil.
+// The invalid options are only logged, use NewUnaryServerInterceptor to get the error.
 func UnaryServerInterceptor(opts *GRPCUnaryInterceptorOptions, redisClient *redis.Client) grpc.UnaryServerInterceptor {
+	o := applyGRPCServerInterceptorOptions(opts)
+	if err := o.validateServer(); err != nil {
+		logrus.Error("invalid gRPC server interceptor options: ", err)
+	}
+	return unaryServerInterceptor(o, redisClient)
+}
+
+//gocognit:ignore
+func unaryServerInterceptor(opts *GRPCUnaryInterceptorOptions, redisClient *redis.Client) grpc.UnaryServerInterceptor {
+	metrics := newRPCMetrics(opts.UseMetrics, "rpc.server.duration")
+	rateLimiterMetrics := newRateLimiterMetrics(opts.UseMetrics, "grpc")
 	return func(
 		ctx context.Context,
 		req interface{},
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (resp interface{}, err error) {
+		begin := time.Now()
 		panicked := true // default value, if not panic, this will be changed to false before the defer func called
 
+		var span trace.Span
 		defer func() {
+			// recover before ending the span, so the span has the status of the recovered error
 			if r := recover(); r != nil || panicked {
 				err = recoverFrom(ctx, r, opts.RecoveryHandlerFunc)
+				if span != nil {
+					setSpanStatus(span, err)
+				}
+				metrics.record(ctx, info.FullMethod, begin, err)
+			}
+			if span != nil {
+				span.End()
 			}
 		}()
 
 		ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
 		defer cancel()
 
-		var span trace.Span
 		if opts.UseOpenTelemetry {
 			requestMetadata, _ := metadata.FromIncomingContext(ctx)
 			metadataCopy := requestMetadata.Copy()
@@ -319,28 +492,14 @@ func UnaryServerInterceptor(opts *GRPCUnaryInterceptorOptions, redisClient *redi
 				trace.WithSpanKind(trace.SpanKindServer),
 				trace.WithAttributes(attr...),
 			)
-			defer span.End()
 
 			messageReceived.Event(ctx, defaultMessageID, req)
 
 		}
 
-		if opts.RateLimiter != nil && redisClient != nil {
-			meta, ok := metadata.FromIncomingContext(ctx)
-			switch {
-			// skip if the ip address metadata is not found
-			case !ok || len(meta.Get(string(ipAddressKey))) <= 0:
-			default:
-				ipAddress := meta.Get(string(ipAddressKey))[0]
-				var userAgent string
-				if len(meta.Get(string(userAgentKey))) > 0 {
-					userAgent = meta.Get(string(userAgentKey))[0]
-				}
-				if ipAddress != "" && isRateLimited(ctx, redisClient, ipAddress, userAgent, opts.RateLimiter) {
-					err = status.Errorf(codes.ResourceExhausted, "too many requests")
-					goto TraceAndReturn
-				}
-			}
+		if opts.RateLimiter != nil && redisClient != nil && isRequestRateLimited(ctx, redisClient, opts.RateLimiter, rateLimiterMetrics) {
+			err = status.Errorf(codes.ResourceExhausted, "too many requests")
+			goto TraceAndReturn
 		}
 
 		resp, err = handler(ctx, req)
@@ -356,6 +515,7 @@ func UnaryServerInterceptor(opts *GRPCUnaryInterceptorOptions, redisClient *redi
 				messageSent.Event(ctx, defaultMessageID, resp)
 			}
 		}
+		metrics.record(ctx, info.FullMethod, begin, err)
 
 		panicked = false
 		return resp, err
@@ -373,7 +533,24 @@ func recoverFrom(ctx context.Context, p interface{}, r RecoveryHandlerFunc) erro
 	return r(ctx, p)
 }
 
-func isRateLimited(ctx context.Context, redisClient *redis.Client, ip, userAgent string, ratelimiter *GRPCRateLimiter) bool {
+// isRequestRateLimited checks the rate limit using the ip address and user agent from the incoming metadata
+func isRequestRateLimited(ctx context.Context, redisClient *redis.Client, ratelimiter *GRPCRateLimiter, metrics *rateLimiterMetrics) bool {
+	meta, ok := metadata.FromIncomingContext(ctx)
+	// skip if the ip address metadata is not found
+	if !ok || len(meta.Get(string(ipAddressKey))) <= 0 {
+		return false
+	}
+
+	ipAddress := meta.Get(string(ipAddressKey))[0]
+	var userAgent string
+	if len(meta.Get(string(userAgentKey))) > 0 {
+		userAgent = meta.Get(string(userAgentKey))[0]
+	}
+
+	return ipAddress != "" && isRateLimited(ctx, redisClient, ipAddress, userAgent, ratelimiter, metrics)
+}
+
+func isRateLimited(ctx context.Context, redisClient *redis.Client, ip, userAgent string, ratelimiter *GRPCRateLimiter, metrics *rateLimiterMetrics) bool {
 	switch {
 	case internal.IsPrivateIP(ip), utils.Contains[string](ratelimiter.ExcludedIPs, ip):
 		return false
@@ -396,6 +573,7 @@ func isRateLimited(ctx context.Context, redisClient *redis.Client, ip, userAgent
 		return false
 	}
 
+	metrics.record(ctx, limiterCtx.Reached)
 	if limiterCtx.Reached {
 		return true
 	}
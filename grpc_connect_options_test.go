@@ -0,0 +1,70 @@
+package connect
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestUnaryClientInterceptor_fallback(t *testing.T) {
+	breaker := newTestSlidingWindowCircuitBreaker(t, &SlidingWindowCircuitBreakerOptions{ConsecutiveFailures: 1})
+	interceptor := UnaryClientInterceptor(&GRPCUnaryInterceptorOptions{
+		UseCircuitBreaker: true,
+		CircuitBreaker:    breaker,
+		MethodPolicies: GRPCMethodPolicies{
+			"/pkg.Lookup/*": {
+				Fallback: func(_ context.Context, _ string, _, reply interface{}, err error) error {
+					assert.ErrorIs(t, err, ErrCircuitOpen)
+					reply.(*wrapperspb.StringValue).Value = "cached"
+					return nil
+				},
+			},
+		},
+	})
+
+	calls := 0
+	invoker := func(_ context.Context, _ string, _, _ interface{}, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+		calls++
+		return status.Error(codes.Internal, "internal")
+	}
+
+	// the first call trips the circuit without calling the fallback
+	reply := &wrapperspb.StringValue{}
+	err := interceptor(context.Background(), "/pkg.Lookup/Get", nil, reply, nil, invoker)
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Empty(t, reply.Value)
+
+	err = interceptor(context.Background(), "/pkg.Lookup/Get", nil, reply, nil, invoker)
+	require.NoError(t, err)
+	assert.Equal(t, "cached", reply.Value)
+	assert.Equal(t, 1, calls)
+
+	t.Run("method without fallback", func(t *testing.T) {
+		_ = breaker.Execute(context.Background(), "/pkg.Other/Get", func(_ context.Context) error { return assert.AnError })
+		err := interceptor(context.Background(), "/pkg.Other/Get", nil, reply, nil, invoker)
+		assert.ErrorIs(t, err, ErrCircuitOpen)
+	})
+}
+
+func TestUnaryServerInterceptor_recoverPanic(t *testing.T) {
+	recorder := setupSpanRecorder(t)
+	interceptor := UnaryServerInterceptor(&GRPCUnaryInterceptorOptions{UseOpenTelemetry: true, Timeout: time.Second}, nil)
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/pkg.Service/Method"}
+	_, err := interceptor(context.Background(), nil, info, func(_ context.Context, _ interface{}) (interface{}, error) {
+		panic("boom")
+	})
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, otelcodes.Error, spans[0].Status().Code)
+}
@@ -0,0 +1,280 @@
+package connect
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/proto"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// hedgingLatencySamples is the number of the latest latencies used to compute the p95 latency per method
+	hedgingLatencySamples = 100
+	// hedgingMinLatencySamples is the minimum number of latencies needed before the p95 latency is used as the delay
+	hedgingMinLatencySamples = 20
+)
+
+var defaultGRPCHedgingPolicy = GRPCHedgingPolicy{
+	MaxHedges:   1,
+	MaxInFlight: 10,
+}
+
+// GRPCHedgingPolicy sends hedged attempts for the idempotent methods, the first success is taken and the rest are canceled.
+// Only methods marked as idempotent by GRPCMethodPolicy.Idempotent are hedged.
+// Like the gRPC hedging policy, the hedged attempts are not retried and don't consume the retry budget,
+// a failure with a retryable code of the retry policy only starts the next hedge without waiting for the delay.
+// The policy keeps the latencies and the in-flight hedges, so don't share it between interceptors.
+type GRPCHedgingPolicy struct {
+	// Delay before sending the next hedged attempt.
+	// When zero, the observed p95 latency of the method is used
+	Delay time.Duration
+
+	// MaxHedges maximum hedged attempts per call, excluding the original attempt.
+	// Default is 1
+	MaxHedges int
+
+	// MaxInFlight maximum hedged attempts in-flight at the same time across all calls.
+	// Default is 10
+	MaxInFlight int
+
+	inFlight  atomic.Int64
+	latencies sync.Map // map[string]*latencyWindow
+}
+
+type hedgeResult struct {
+	reply proto.Message
+	err   error
+
+	// the metadata and the peer of the attempt, only the ones of the returned result are copied to the call options
+	header  metadata.MD
+	trailer metadata.MD
+	peer    peer.Peer
+}
+
+// hedgeCallOptions the call options writing the metadata or the peer of the call.
+// They are written by every hedged attempt concurrently, so each attempt has its own and the returned result is copied back.
+type hedgeCallOptions struct {
+	headers  []*metadata.MD
+	trailers []*metadata.MD
+	peers    []*peer.Peer
+}
+
+// splitHedgeCallOptions removes the header, trailer and peer call options from the options of the attempts
+func splitHedgeCallOptions(opts []grpc.CallOption) ([]grpc.CallOption, *hedgeCallOptions) {
+	attemptOpts := make([]grpc.CallOption, 0, len(opts))
+	callOpts := &hedgeCallOptions{}
+	for _, opt := range opts {
+		switch opt := opt.(type) {
+		case grpc.HeaderCallOption:
+			callOpts.headers = append(callOpts.headers, opt.HeaderAddr)
+		case *grpc.HeaderCallOption:
+			callOpts.headers = append(callOpts.headers, opt.HeaderAddr)
+		case grpc.TrailerCallOption:
+			callOpts.trailers = append(callOpts.trailers, opt.TrailerAddr)
+		case *grpc.TrailerCallOption:
+			callOpts.trailers = append(callOpts.trailers, opt.TrailerAddr)
+		case grpc.PeerCallOption:
+			callOpts.peers = append(callOpts.peers, opt.PeerAddr)
+		case *grpc.PeerCallOption:
+			callOpts.peers = append(callOpts.peers, opt.PeerAddr)
+		default:
+			attemptOpts = append(attemptOpts, opt)
+		}
+	}
+	return attemptOpts, callOpts
+}
+
+// copyResult writes the metadata and the peer of the result to the call options
+func (c *hedgeCallOptions) copyResult(res *hedgeResult) {
+	for _, header := range c.headers {
+		*header = res.header
+	}
+	for _, trailer := range c.trailers {
+		*trailer = res.trailer
+	}
+	for _, p := range c.peers {
+		*p = res.peer
+	}
+}
+
+// Validate returns the joined errors of every invalid field
+func (p *GRPCHedgingPolicy) Validate() error {
+	v := &optionsValidator{}
+	v.check(p.Delay >= 0, "Delay", "must not be negative")
+	v.check(p.MaxHedges >= 0, "MaxHedges", "must not be negative")
+	v.check(p.MaxInFlight >= 0, "MaxInFlight", "must not be negative")
+	return v.err()
+}
+
+// latencyWindow keeps the latest latencies of a method
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples [hedgingLatencySamples]time.Duration
+	count   int
+	next    int
+}
+
+func (w *latencyWindow) observe(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % hedgingLatencySamples
+	w.count = min(w.count+1, hedgingLatencySamples)
+}
+
+// p95 returns the p95 latency, false when there are not enough samples
+func (w *latencyWindow) p95() (time.Duration, bool) {
+	w.mu.Lock()
+	samples := slices.Clone(w.samples[:w.count])
+	w.mu.Unlock()
+
+	if len(samples) < hedgingMinLatencySamples {
+		return 0, false
+	}
+
+	slices.Sort(samples)
+	return samples[len(samples)*95/100], true
+}
+
+func (p *GRPCHedgingPolicy) observe(method string, d time.Duration) {
+	w, _ := p.latencies.LoadOrStore(method, &latencyWindow{})
+	w.(*latencyWindow).observe(d)
+}
+
+// delay returns the delay before sending a hedged attempt, false when it's unknown yet
+func (p *GRPCHedgingPolicy) delay(method string) (time.Duration, bool) {
+	if p.Delay > 0 {
+		return p.Delay, true
+	}
+
+	w, ok := p.latencies.Load(method)
+	if !ok {
+		return 0, false
+	}
+	return w.(*latencyWindow).p95()
+}
+
+func (p *GRPCHedgingPolicy) maxHedges() int {
+	if p.MaxHedges <= 0 {
+		return defaultGRPCHedgingPolicy.MaxHedges
+	}
+	return p.MaxHedges
+}
+
+// acquire reserves an in-flight hedge, returns false when the cap is reached
+func (p *GRPCHedgingPolicy) acquire() bool {
+	maxInFlight := int64(p.MaxInFlight)
+	if maxInFlight <= 0 {
+		maxInFlight = int64(defaultGRPCHedgingPolicy.MaxInFlight)
+	}
+
+	if p.inFlight.Add(1) > maxInFlight {
+		p.inFlight.Add(-1)
+		return false
+	}
+	return true
+}
+
+func (p *GRPCHedgingPolicy) release() {
+	p.inFlight.Add(-1)
+}
+
+// invoke sends hedged attempts when the method is idempotent, otherwise it's the same as retryableInvoke
+func (o *GRPCUnaryInterceptorOptions) invoke(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if o.Hedging == nil || !o.idempotent {
+		return o.retryableInvoke(ctx, method, req, reply, cc, invoker, opts...)
+	}
+
+	replyMessage, ok := reply.(proto.Message)
+	delay, known := o.Hedging.delay(method)
+	if !ok || !known {
+		begin := time.Now()
+		err := o.retryableInvoke(ctx, method, req, reply, cc, invoker, opts...)
+		if err == nil {
+			o.Hedging.observe(method, time.Since(begin))
+		}
+		return err
+	}
+
+	return o.hedgedInvoke(ctx, delay, method, req, replyMessage, cc, invoker, opts...)
+}
+
+//gocognit:ignore
+func (o *GRPCUnaryInterceptorOptions) hedgedInvoke(ctx context.Context, delay time.Duration, method string, req interface{}, reply proto.Message, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	ctx, cancel := context.WithCancel(ctx) // cancel the losing attempts
+	defer cancel()
+
+	opts, callOpts := splitHedgeCallOptions(opts)
+	maxHedges := o.Hedging.maxHedges()
+	results := make(chan *hedgeResult, maxHedges+1)
+	begin := time.Now()
+
+	o.startHedge(ctx, 0, results, method, req, reply, cc, invoker, opts...)
+	inFlight, hedges := 1, 0
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	startNextHedge := func() {
+		if hedges >= maxHedges || !o.Hedging.acquire() {
+			return
+		}
+		hedges++
+		inFlight++
+		o.startHedge(ctx, hedges, results, method, req, reply, cc, invoker, opts...)
+		timer.Reset(delay)
+	}
+
+	var err error
+	for inFlight > 0 {
+		select {
+		case <-timer.C:
+			startNextHedge()
+		case res := <-results:
+			inFlight--
+			if res.err == nil {
+				proto.Reset(reply)
+				proto.Merge(reply, res.reply)
+				callOpts.copyResult(res)
+				o.Hedging.observe(method, time.Since(begin))
+				return nil
+			}
+
+			err = res.err
+			callOpts.copyResult(res) // the metadata of the last failed attempt is kept when every attempt fails
+			if !o.retryPolicy().isRetryable(err) {
+				return err
+			}
+			startNextHedge() // don't wait for the delay when an attempt is already failed
+		}
+	}
+
+	return err
+}
+
+// startHedge invokes an attempt asynchronously, without retries, with its own reply and metadata, hedge 0 is the original attempt.
+// The header, trailer and peer call options must be already removed by splitHedgeCallOptions.
+func (o *GRPCUnaryInterceptorOptions) startHedge(ctx context.Context, hedge int, results chan<- *hedgeResult, method string, req interface{}, reply proto.Message, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) {
+	res := &hedgeResult{reply: proto.Clone(reply)}
+	proto.Reset(res.reply)
+	opts = append(opts[:len(opts):len(opts)], grpc.Header(&res.header), grpc.Trailer(&res.trailer), grpc.Peer(&res.peer))
+
+	go func() {
+		if hedge > 0 {
+			defer o.Hedging.release()
+		}
+
+		attrs := []attribute.KeyValue{attemptKey.Int(hedge + 1), hedgeKey.Int(hedge)}
+		res.err = o.invokeAttempt(ctx, attrs, method, req, res.reply, cc, invoker, opts...)
+		results <- res
+	}()
+}
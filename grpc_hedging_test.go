@@ -0,0 +1,172 @@
+package connect
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestGRPCUnaryInterceptorOptions_invoke_hedging(t *testing.T) {
+	newOptions := func(idempotent bool) *GRPCUnaryInterceptorOptions {
+		return (&GRPCUnaryInterceptorOptions{
+			Hedging: &GRPCHedgingPolicy{Delay: 10 * time.Millisecond},
+			MethodPolicies: GRPCMethodPolicies{
+				"/pkg.Lookup/*": {Idempotent: idempotent},
+			},
+		}).forMethod("/pkg.Lookup/Get")
+	}
+
+	// the first attempt is slow, the hedged attempt is fast
+	newInvoker := func(calls *atomic.Int32, canceled chan<- struct{}) grpc.UnaryInvoker {
+		return func(ctx context.Context, _ string, _, reply interface{}, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+			if calls.Add(1) == 1 {
+				<-ctx.Done()
+				canceled <- struct{}{}
+				return status.FromContextError(ctx.Err()).Err()
+			}
+			reply.(*wrapperspb.StringValue).Value = "hedged"
+			return nil
+		}
+	}
+
+	t.Run("hedged attempt wins and the loser is canceled", func(t *testing.T) {
+		var calls atomic.Int32
+		canceled := make(chan struct{}, 1)
+		o := newOptions(true)
+
+		reply := &wrapperspb.StringValue{}
+		err := o.invoke(context.Background(), "/pkg.Lookup/Get", nil, reply, nil, newInvoker(&calls, canceled))
+		require.NoError(t, err)
+		assert.Equal(t, "hedged", reply.Value)
+		assert.EqualValues(t, 2, calls.Load())
+
+		select {
+		case <-canceled:
+		case <-time.After(time.Second):
+			t.Fatal("the first attempt is not canceled")
+		}
+		assert.Zero(t, o.Hedging.inFlight.Load())
+	})
+
+	t.Run("winner's metadata is copied to the call options", func(t *testing.T) {
+		recorder := setupSpanRecorder(t)
+		var calls atomic.Int32
+		o := newOptions(true)
+		o.UseOpenTelemetry = true
+
+		invoker := func(ctx context.Context, _ string, _, reply interface{}, _ *grpc.ClientConn, opts ...grpc.CallOption) error {
+			attempt := strconv.Itoa(int(calls.Add(1)))
+			// emulate grpc writing the metadata of the attempt after the call
+			defer func() {
+				for _, opt := range opts {
+					switch opt := opt.(type) {
+					case grpc.HeaderCallOption:
+						*opt.HeaderAddr = metadata.Pairs("attempt", attempt)
+					case grpc.TrailerCallOption:
+						*opt.TrailerAddr = metadata.Pairs("attempt", attempt)
+					}
+				}
+			}()
+			if attempt == "1" {
+				<-ctx.Done()
+				return status.FromContextError(ctx.Err()).Err()
+			}
+			reply.(*wrapperspb.StringValue).Value = "hedged"
+			return nil
+		}
+
+		cc, err := grpc.NewClient("passthrough:///localhost:0", grpc.WithTransportCredentials(insecure.NewCredentials()))
+		require.NoError(t, err)
+		defer cc.Close()
+
+		var header, trailer metadata.MD
+		err = o.invoke(context.Background(), "/pkg.Lookup/Get", nil, &wrapperspb.StringValue{}, cc, invoker, grpc.Header(&header), grpc.Trailer(&trailer))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"2"}, header.Get("attempt"))
+		assert.Equal(t, []string{"2"}, trailer.Get("attempt"))
+
+		// every attempt has a single client span, identified by the hedge attribute,
+		// the span of the canceled attempt ends after the call returns
+		require.Eventually(t, func() bool { return len(recorder.Ended()) == 2 }, time.Second, 5*time.Millisecond)
+		spans := recorder.Ended()
+		hedges := map[int64]int64{}
+		for _, span := range spans {
+			assert.Equal(t, trace.SpanKindClient, span.SpanKind())
+			attrs := spanAttributes(span)
+			hedges[attrs[hedgeKey].AsInt64()] = attrs[attemptKey].AsInt64()
+		}
+		assert.Equal(t, map[int64]int64{0: 1, 1: 2}, hedges)
+	})
+
+	t.Run("hedged attempts are not retried", func(t *testing.T) {
+		var calls atomic.Int32
+		o := newOptions(true)
+		o.RetryCount = 3
+		o.RetryInterval = time.Millisecond
+
+		invoker := func(_ context.Context, _ string, _, _ interface{}, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+			calls.Add(1)
+			return status.Error(codes.Unavailable, "unavailable")
+		}
+
+		err := o.invoke(context.Background(), "/pkg.Lookup/Get", nil, &wrapperspb.StringValue{}, nil, invoker)
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		assert.EqualValues(t, 2, calls.Load(), "the original attempt and a single hedge")
+	})
+
+	t.Run("not idempotent method is not hedged", func(t *testing.T) {
+		var calls atomic.Int32
+		canceled := make(chan struct{}, 1)
+		o := newOptions(false)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		err := o.invoke(ctx, "/pkg.Lookup/Get", nil, &wrapperspb.StringValue{}, nil, newInvoker(&calls, canceled))
+		assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+		assert.EqualValues(t, 1, calls.Load())
+	})
+
+	t.Run("in-flight hedges are capped", func(t *testing.T) {
+		var calls atomic.Int32
+		canceled := make(chan struct{}, 1)
+		o := newOptions(true)
+		o.Hedging.MaxInFlight = 1
+		require.True(t, o.Hedging.acquire())
+		defer o.Hedging.release()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		err := o.invoke(ctx, "/pkg.Lookup/Get", nil, &wrapperspb.StringValue{}, nil, newInvoker(&calls, canceled))
+		assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+		assert.EqualValues(t, 1, calls.Load())
+	})
+}
+
+func TestGRPCHedgingPolicy_delay(t *testing.T) {
+	p := &GRPCHedgingPolicy{}
+
+	_, ok := p.delay("/pkg.Lookup/Get")
+	assert.False(t, ok)
+
+	for i := 1; i <= 100; i++ {
+		p.observe("/pkg.Lookup/Get", time.Duration(i)*time.Millisecond)
+	}
+
+	delay, ok := p.delay("/pkg.Lookup/Get")
+	require.True(t, ok)
+	assert.Equal(t, 96*time.Millisecond, delay)
+}
@@ -0,0 +1,266 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	// defaultMethodPolicyKey is the method policy key applied to every method without a more specific policy
+	defaultMethodPolicyKey = "*"
+	// serviceWildcardSuffix is the suffix of a method policy key applied to every method of a service
+	serviceWildcardSuffix = "/*"
+)
+
+// GRPCMethodPolicy overrides the client interceptor options for specific methods.
+// Zero values and nil mean the value from GRPCUnaryInterceptorOptions is used.
+type GRPCMethodPolicy struct {
+	// Timeout value, will return context deadline exceeded when the operation exceeds the duration
+	Timeout time.Duration
+
+	// RetryCount retry the operation if found error, nil means not overridden and 0 disables the retries of the method
+	RetryCount *int
+
+	// RetryInterval next interval for retry.
+	RetryInterval time.Duration
+
+	// RetryPolicy overrides the retry policy of the method, the Budget of GRPCUnaryInterceptorOptions.RetryPolicy
+	// is used when its Budget is nil
+	RetryPolicy *GRPCRetryPolicy
+
+	// UseCircuitBreaker flag if the method will implement a circuit breaker, nil means not overridden
+	UseCircuitBreaker *bool
+
+	// Idempotent flag if the method is safe to be hedged, see GRPCUnaryInterceptorOptions.Hedging.
+	// It's not inherited from the less specific policies, so false disables the hedging of the method
+	Idempotent bool
+
+	// Fallback is called when the call is rejected by the circuit breaker
+	Fallback GRPCFallbackFunc
+}
+
+// GRPCFallbackFunc is called when the call is rejected by the circuit breaker, err is either ErrCircuitOpen or ErrMaxConcurrency.
+// It can fill the reply, e.g. from a cache, and return nil, or return a status error.
+type GRPCFallbackFunc func(ctx context.Context, method string, req, reply interface{}, err error) error
+
+// GRPCMethodPolicies method policy table, the key is one of:
+//   - exact full method name, e.g. /pkg.Service/Method
+//   - service wildcard, e.g. /pkg.Service/*
+//   - default, *
+//
+// The most specific policy is used.
+type GRPCMethodPolicies map[string]*GRPCMethodPolicy
+
+// lookup returns the most specific policy for the full method name, nil if not found
+func (p GRPCMethodPolicies) lookup(fullMethod string) *GRPCMethodPolicy {
+	if len(p) == 0 {
+		return nil
+	}
+
+	if policy, ok := p[fullMethod]; ok {
+		return policy
+	}
+
+	if i := strings.LastIndex(fullMethod, "/"); i > 0 {
+		if policy, ok := p[fullMethod[:i]+serviceWildcardSuffix]; ok {
+			return policy
+		}
+	}
+
+	return p[defaultMethodPolicyKey]
+}
+
+// Validate returns the joined errors of every invalid policy, keyed by the method policy key
+func (p GRPCMethodPolicies) Validate() error {
+	keys := make([]string, 0, len(p))
+	for key := range p {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys) // report the errors in a stable order
+
+	v := &optionsValidator{}
+	for _, key := range keys {
+		field := fmt.Sprintf("[%s]", key)
+		v.check(key == defaultMethodPolicyKey || strings.HasPrefix(key, "/"), field, "must be *, /pkg.Service/* or /pkg.Service/Method")
+		if p[key] == nil {
+			v.check(false, field, "must not be nil")
+			continue
+		}
+		v.nested(field, p[key].Validate())
+	}
+	return v.err()
+}
+
+// Validate returns the joined errors of every invalid field
+func (p *GRPCMethodPolicy) Validate() error {
+	v := &optionsValidator{}
+	v.check(p.Timeout >= 0, "Timeout", "must not be negative")
+	v.check(p.RetryCount == nil || *p.RetryCount >= 0, "RetryCount", "must not be negative")
+	v.check(p.RetryInterval >= 0, "RetryInterval", "must not be negative")
+	if p.RetryPolicy != nil {
+		v.nested("RetryPolicy", p.RetryPolicy.Validate())
+	}
+	return v.err()
+}
+
+// forMethod returns the options with the method policy applied
+func (o *GRPCUnaryInterceptorOptions) forMethod(fullMethod string) *GRPCUnaryInterceptorOptions {
+	policy := o.MethodPolicies.lookup(fullMethod)
+	if policy == nil {
+		return o
+	}
+
+	methodOptions := *o
+	if policy.Timeout > 0 {
+		methodOptions.Timeout = policy.Timeout
+	}
+	if policy.RetryCount != nil {
+		methodOptions.RetryCount = *policy.RetryCount
+	}
+	if policy.RetryInterval > 0 {
+		methodOptions.RetryInterval = policy.RetryInterval
+	}
+	if policy.RetryPolicy != nil {
+		retryPolicy := *policy.RetryPolicy
+		if retryPolicy.Budget == nil && o.RetryPolicy != nil {
+			retryPolicy.Budget = o.RetryPolicy.Budget
+		}
+		methodOptions.RetryPolicy = &retryPolicy
+	}
+	if policy.UseCircuitBreaker != nil {
+		methodOptions.UseCircuitBreaker = *policy.UseCircuitBreaker
+	}
+	methodOptions.idempotent = policy.Idempotent
+	methodOptions.fallback = policy.Fallback
+
+	return &methodOptions
+}
+
+type serviceConfig struct {
+	MethodConfig []methodConfig `json:"methodConfig"`
+}
+
+type methodConfig struct {
+	Name        []methodName `json:"name"`
+	Timeout     string       `json:"timeout"`
+	RetryPolicy *struct {
+		MaxAttempts          int          `json:"maxAttempts"`
+		InitialBackoff       string       `json:"initialBackoff"`
+		MaxBackoff           string       `json:"maxBackoff"`
+		BackoffMultiplier    float64      `json:"backoffMultiplier"`
+		RetryableStatusCodes []codes.Code `json:"retryableStatusCodes"`
+	} `json:"retryPolicy"`
+	// HedgingPolicy marks the method as idempotent, the hedging itself is configured by GRPCHedgingPolicy
+	HedgingPolicy *struct{} `json:"hedgingPolicy"`
+	// UseCircuitBreaker is not part of the gRPC service config, it's an extension for this package
+	UseCircuitBreaker *bool `json:"useCircuitBreaker"`
+}
+
+type methodName struct {
+	Service string `json:"service"`
+	Method  string `json:"method"`
+}
+
+// key returns the method policy key of the name
+func (n methodName) key() string {
+	switch {
+	case n.Service == "":
+		return defaultMethodPolicyKey
+	case n.Method == "":
+		return "/" + n.Service + serviceWildcardSuffix
+	default:
+		return "/" + n.Service + "/" + n.Method
+	}
+}
+
+// ParseGRPCMethodPolicies parses the method policies from gRPC service config JSON, e.g.
+//
+//	{
+//	  "methodConfig": [{
+//	    "name": [{"service": "pkg.Service", "method": "Search"}],
+//	    "timeout": "5s",
+//	    "retryPolicy": {
+//	      "maxAttempts": 3,
+//	      "initialBackoff": "0.1s",
+//	      "maxBackoff": "1s",
+//	      "backoffMultiplier": 2,
+//	      "retryableStatusCodes": ["UNAVAILABLE"]
+//	    },
+//	    "useCircuitBreaker": true
+//	  }, {
+//	    "name": [{"service": "pkg.Lookup"}],
+//	    "hedgingPolicy": {}
+//	  }]
+//	}
+//
+// A name without method applies to the whole service and an empty name applies to every method.
+// A method with hedgingPolicy is marked as idempotent.
+// The retryPolicy maps to RetryCount, RetryInterval and RetryPolicy.
+func ParseGRPCMethodPolicies(serviceConfigJSON []byte) (GRPCMethodPolicies, error) {
+	var cfg serviceConfig
+	if err := json.Unmarshal(serviceConfigJSON, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid service config: %w", err)
+	}
+
+	policies := GRPCMethodPolicies{}
+	for i, mc := range cfg.MethodConfig {
+		policy, err := mc.toPolicy()
+		if err != nil {
+			return nil, fmt.Errorf("invalid methodConfig[%d]: %w", i, err)
+		}
+
+		for _, name := range mc.Name {
+			policies[name.key()] = policy
+		}
+	}
+
+	return policies, nil
+}
+
+func (mc methodConfig) toPolicy() (*GRPCMethodPolicy, error) {
+	policy := &GRPCMethodPolicy{
+		UseCircuitBreaker: mc.UseCircuitBreaker,
+		Idempotent:        mc.HedgingPolicy != nil,
+	}
+
+	if mc.Timeout != "" {
+		timeout, err := time.ParseDuration(mc.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout: %w", err)
+		}
+		policy.Timeout = timeout
+	}
+
+	if mc.RetryPolicy == nil {
+		return policy, nil
+	}
+
+	retryCount := mc.RetryPolicy.MaxAttempts
+	policy.RetryCount = &retryCount
+	policy.RetryPolicy = &GRPCRetryPolicy{
+		RetryableCodes:    mc.RetryPolicy.RetryableStatusCodes,
+		BackoffMultiplier: mc.RetryPolicy.BackoffMultiplier,
+	}
+	if mc.RetryPolicy.InitialBackoff != "" {
+		interval, err := time.ParseDuration(mc.RetryPolicy.InitialBackoff)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retryPolicy.initialBackoff: %w", err)
+		}
+		policy.RetryInterval = interval
+	}
+	if mc.RetryPolicy.MaxBackoff != "" {
+		maxBackoff, err := time.ParseDuration(mc.RetryPolicy.MaxBackoff)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retryPolicy.maxBackoff: %w", err)
+		}
+		policy.RetryPolicy.MaxBackoff = maxBackoff
+	}
+
+	return policy, policy.Validate()
+}
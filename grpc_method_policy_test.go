@@ -0,0 +1,129 @@
+package connect
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestGRPCMethodPolicies_lookup(t *testing.T) {
+	exact := &GRPCMethodPolicy{Timeout: 200 * time.Millisecond}
+	service := &GRPCMethodPolicy{Timeout: 5 * time.Second}
+	def := &GRPCMethodPolicy{Timeout: time.Second}
+
+	policies := GRPCMethodPolicies{
+		"/pkg.Search/Lookup": exact,
+		"/pkg.Search/*":      service,
+		"*":                  def,
+	}
+
+	assert.Same(t, exact, policies.lookup("/pkg.Search/Lookup"))
+	assert.Same(t, service, policies.lookup("/pkg.Search/Query"))
+	assert.Same(t, def, policies.lookup("/pkg.Other/Query"))
+	assert.Nil(t, GRPCMethodPolicies{}.lookup("/pkg.Other/Query"))
+}
+
+func TestGRPCUnaryInterceptorOptions_forMethod(t *testing.T) {
+	useCircuitBreaker := true
+	opts := &GRPCUnaryInterceptorOptions{
+		Timeout:       time.Second,
+		RetryCount:    1,
+		RetryInterval: 10 * time.Millisecond,
+		MethodPolicies: GRPCMethodPolicies{
+			"/pkg.Search/*": {Timeout: 5 * time.Second, UseCircuitBreaker: &useCircuitBreaker},
+		},
+	}
+
+	t.Run("without policy", func(t *testing.T) {
+		assert.Same(t, opts, opts.forMethod("/pkg.Other/Query"))
+	})
+
+	t.Run("with policy", func(t *testing.T) {
+		methodOpts := opts.forMethod("/pkg.Search/Query")
+		assert.Equal(t, 5*time.Second, methodOpts.Timeout)
+		assert.True(t, methodOpts.UseCircuitBreaker)
+		assert.Equal(t, 1, methodOpts.RetryCount)
+		assert.Equal(t, 10*time.Millisecond, methodOpts.RetryInterval)
+
+		// the original options should not be changed
+		assert.Equal(t, time.Second, opts.Timeout)
+		assert.False(t, opts.UseCircuitBreaker)
+	})
+
+	t.Run("disable the retries and the hedging of a method", func(t *testing.T) {
+		noRetry := 0
+		opts := &GRPCUnaryInterceptorOptions{
+			RetryCount:    3,
+			RetryInterval: 10 * time.Millisecond,
+			Hedging:       &GRPCHedgingPolicy{MaxHedges: 1, Delay: time.Millisecond},
+			RetryPolicy:   &GRPCRetryPolicy{Budget: &GRPCRetryBudget{Ratio: 0.1}},
+			MethodPolicies: GRPCMethodPolicies{
+				"/pkg.Search/*":     {Idempotent: true},
+				"/pkg.Search/Write": {RetryCount: &noRetry, RetryPolicy: &GRPCRetryPolicy{MaxBackoff: time.Second}},
+			},
+		}
+
+		assert.True(t, opts.forMethod("/pkg.Search/Query").idempotent)
+		assert.Equal(t, 3, opts.forMethod("/pkg.Search/Query").RetryCount)
+
+		methodOpts := opts.forMethod("/pkg.Search/Write")
+		assert.Equal(t, 0, methodOpts.RetryCount)
+		assert.False(t, methodOpts.idempotent)
+		assert.Equal(t, time.Second, methodOpts.RetryPolicy.MaxBackoff)
+		assert.Same(t, opts.RetryPolicy.Budget, methodOpts.RetryPolicy.Budget, "the budget is inherited")
+	})
+}
+
+func TestParseGRPCMethodPolicies(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		policies, err := ParseGRPCMethodPolicies([]byte(`{
+			"methodConfig": [
+				{"name": [{"service": "pkg.Search", "method": "Lookup"}], "timeout": "0.2s"},
+				{"name": [{"service": "pkg.Search"}], "timeout": "5s", "retryPolicy": {"maxAttempts": 3, "initialBackoff": "0.1s", "maxBackoff": "1s", "backoffMultiplier": 1.5, "retryableStatusCodes": ["UNAVAILABLE", "RESOURCE_EXHAUSTED"]}, "useCircuitBreaker": true},
+				{"name": [{}], "timeout": "1s"}
+			]
+		}`))
+		require.NoError(t, err)
+		require.Len(t, policies, 3)
+
+		assert.Equal(t, 200*time.Millisecond, policies["/pkg.Search/Lookup"].Timeout)
+
+		service := policies["/pkg.Search/*"]
+		assert.Equal(t, 5*time.Second, service.Timeout)
+		require.NotNil(t, service.RetryCount)
+		assert.Equal(t, 3, *service.RetryCount)
+		assert.Equal(t, 100*time.Millisecond, service.RetryInterval)
+		require.NotNil(t, service.RetryPolicy)
+		assert.Equal(t, time.Second, service.RetryPolicy.MaxBackoff)
+		assert.Equal(t, 1.5, service.RetryPolicy.BackoffMultiplier)
+		assert.Equal(t, []codes.Code{codes.Unavailable, codes.ResourceExhausted}, service.RetryPolicy.RetryableCodes)
+		require.NotNil(t, service.UseCircuitBreaker)
+		assert.True(t, *service.UseCircuitBreaker)
+
+		assert.Equal(t, time.Second, policies["*"].Timeout)
+	})
+
+	t.Run("invalid timeout", func(t *testing.T) {
+		_, err := ParseGRPCMethodPolicies([]byte(`{"methodConfig": [{"name": [{}], "timeout": "soon"}]}`))
+		assert.ErrorContains(t, err, "invalid methodConfig[0]")
+	})
+
+	t.Run("invalid retry policy", func(t *testing.T) {
+		_, err := ParseGRPCMethodPolicies([]byte(`{"methodConfig": [{"name": [{}], "retryPolicy": {"maxAttempts": 3, "backoffMultiplier": 0.5}}]}`))
+		assert.ErrorContains(t, err, "RetryPolicy.BackoffMultiplier: must be at least 1")
+
+		_, err = ParseGRPCMethodPolicies([]byte(`{"methodConfig": [{"name": [{}], "retryPolicy": {"maxAttempts": 3, "maxBackoff": "later"}}]}`))
+		assert.ErrorContains(t, err, "invalid retryPolicy.maxBackoff")
+
+		_, err = ParseGRPCMethodPolicies([]byte(`{"methodConfig": [{"name": [{}], "retryPolicy": {"retryableStatusCodes": ["SOMETIMES"]}}]}`))
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		_, err := ParseGRPCMethodPolicies([]byte(`{`))
+		assert.Error(t, err)
+	})
+}
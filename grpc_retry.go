@@ -0,0 +1,110 @@
+package connect
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/jpillora/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// retryPushbackKey is the trailer key used by the server to tell the client when to retry, see gRFC A6
+const retryPushbackKey = "grpc-retry-pushback-ms"
+
+// GRPCRetryPolicy retry policy for the gRPC client interceptor,
+// the number of attempts and the initial backoff are taken from RetryCount and RetryInterval
+type GRPCRetryPolicy struct {
+	// RetryableCodes status codes that will be retried.
+	// Default is Unavailable
+	RetryableCodes []codes.Code
+
+	// BackoffMultiplier multiplies the backoff after each retry.
+	// Default is 2
+	BackoffMultiplier float64
+
+	// MaxBackoff maximum backoff between retries.
+	// Default is 10 seconds
+	MaxBackoff time.Duration
+
+	// Jitter randomizes the backoff between RetryInterval and the computed backoff
+	Jitter bool
+
+	// PerAttemptTimeout timeout of each attempt, Timeout still bounds all the attempts.
+	// When zero, each attempt is only bounded by Timeout
+	PerAttemptTimeout time.Duration
+
+	// Budget limits the retries per target, when nil the retries are only limited by RetryCount
+	Budget *GRPCRetryBudget
+}
+
+var defaultGRPCRetryPolicy = &GRPCRetryPolicy{
+	RetryableCodes:    []codes.Code{codes.Unavailable},
+	BackoffMultiplier: 2,
+}
+
+// Validate returns the joined errors of every invalid field
+func (p *GRPCRetryPolicy) Validate() error {
+	v := &optionsValidator{}
+	v.check(p.BackoffMultiplier == 0 || p.BackoffMultiplier >= 1, "BackoffMultiplier", "must be at least 1")
+	v.check(p.MaxBackoff >= 0, "MaxBackoff", "must not be negative")
+	v.check(p.PerAttemptTimeout >= 0, "PerAttemptTimeout", "must not be negative")
+	if p.Budget != nil {
+		v.nested("Budget", p.Budget.Validate())
+	}
+	return v.err()
+}
+
+func (o *GRPCUnaryInterceptorOptions) retryPolicy() *GRPCRetryPolicy {
+	if o.RetryPolicy == nil {
+		return defaultGRPCRetryPolicy
+	}
+	return o.RetryPolicy
+}
+
+// isRetryable checks if the error status code is retryable
+func (p *GRPCRetryPolicy) isRetryable(err error) bool {
+	retryableCodes := p.RetryableCodes
+	if len(retryableCodes) == 0 {
+		retryableCodes = defaultGRPCRetryPolicy.RetryableCodes
+	}
+
+	code := status.Code(err)
+	for _, c := range retryableCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns a new backoff for a call, it's not safe to share between calls
+func (p *GRPCRetryPolicy) backoff(initial time.Duration) *backoff.Backoff {
+	multiplier := p.BackoffMultiplier
+	if multiplier <= 0 {
+		multiplier = defaultGRPCRetryPolicy.BackoffMultiplier
+	}
+
+	return &backoff.Backoff{
+		Factor: multiplier,
+		Jitter: p.Jitter,
+		Min:    initial,
+		Max:    p.MaxBackoff,
+	}
+}
+
+// retryPushback returns the retry delay requested by the server, negative means the server asks to not retry.
+// The second value is false when the server doesn't push back.
+func retryPushback(trailer metadata.MD) (time.Duration, bool) {
+	values := trailer.Get(retryPushbackKey)
+	if len(values) == 0 {
+		return 0, false
+	}
+
+	ms, err := strconv.Atoi(values[0])
+	if err != nil || ms < 0 {
+		return -1, true
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
@@ -0,0 +1,131 @@
+package connect
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const retryBudgetBucketCount = 10
+
+var defaultGRPCRetryBudget = GRPCRetryBudget{
+	Ratio:               0.1,
+	MinRetriesPerSecond: 10,
+	Window:              10 * time.Second,
+}
+
+// GRPCRetryBudget limits the retries per target to prevent retry storms when the target degrades.
+// A retry is allowed while the retries over the sliding window stay below
+// Ratio * requests + MinRetriesPerSecond * window seconds.
+// The budget is shared by every call using it, so use one budget per interceptor.
+type GRPCRetryBudget struct {
+	// Ratio of retries to the requests allowed over the window.
+	// Default is 0.1
+	Ratio float64
+
+	// MinRetriesPerSecond retries allowed regardless of the ratio, so low traffic targets can still retry.
+	// Default is 10
+	MinRetriesPerSecond int
+
+	// Window duration of the sliding window.
+	// Default is 10 seconds
+	Window time.Duration
+
+	mu      sync.Mutex
+	targets map[string]*retryBudgetWindow
+	now     func() time.Time
+}
+
+type retryBudgetBucket struct {
+	start    time.Time
+	requests int
+	retries  int
+}
+
+type retryBudgetWindow struct {
+	buckets [retryBudgetBucketCount]retryBudgetBucket
+}
+
+// Validate returns the joined errors of every invalid field
+func (b *GRPCRetryBudget) Validate() error {
+	v := &optionsValidator{}
+	v.check(b.Ratio >= 0 && b.Ratio <= 1, "Ratio", "must be between 0 and 1")
+	v.check(b.MinRetriesPerSecond >= 0, "MinRetriesPerSecond", "must not be negative")
+	v.check(b.Window >= 0, "Window", "must not be negative")
+	return v.err()
+}
+
+// recordRequest deposits a request of the target to the budget
+func (b *GRPCRetryBudget) recordRequest(target string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.currentBucket(target).requests++
+}
+
+// tryRetry withdraws a retry of the target from the budget, returns false when the budget is exhausted
+func (b *GRPCRetryBudget) tryRetry(target string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bucket := b.currentBucket(target)
+	window := b.window()
+	var requests, retries int
+	for _, bk := range b.targets[target].buckets {
+		if b.clock().Sub(bk.start) < window {
+			requests += bk.requests
+			retries += bk.retries
+		}
+	}
+
+	ratio := b.Ratio
+	if ratio <= 0 {
+		ratio = defaultGRPCRetryBudget.Ratio
+	}
+	minRetriesPerSecond := b.MinRetriesPerSecond
+	if minRetriesPerSecond <= 0 {
+		minRetriesPerSecond = defaultGRPCRetryBudget.MinRetriesPerSecond
+	}
+
+	allowed := ratio*float64(requests) + float64(minRetriesPerSecond)*math.Max(window.Seconds(), 1)
+	if float64(retries) >= allowed {
+		return false
+	}
+
+	bucket.retries++
+	return true
+}
+
+// currentBucket returns the target bucket of the current time, must be called with the lock held
+func (b *GRPCRetryBudget) currentBucket(target string) *retryBudgetBucket {
+	if b.targets == nil {
+		b.targets = map[string]*retryBudgetWindow{}
+	}
+	w, ok := b.targets[target]
+	if !ok {
+		w = &retryBudgetWindow{}
+		b.targets[target] = w
+	}
+
+	bucketSize := max(b.window()/retryBudgetBucketCount, time.Nanosecond)
+	start := b.clock().Truncate(bucketSize)
+	bucket := &w.buckets[(start.UnixNano()/int64(bucketSize))%retryBudgetBucketCount]
+	if !bucket.start.Equal(start) { // the bucket is from the previous window, reuse it
+		*bucket = retryBudgetBucket{start: start}
+	}
+	return bucket
+}
+
+func (b *GRPCRetryBudget) window() time.Duration {
+	if b.Window <= 0 {
+		return defaultGRPCRetryBudget.Window
+	}
+	return b.Window
+}
+
+func (b *GRPCRetryBudget) clock() time.Time {
+	if b.now == nil {
+		return time.Now()
+	}
+	return b.now()
+}
@@ -0,0 +1,70 @@
+package connect
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCRetryBudget(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	budget := &GRPCRetryBudget{
+		Ratio:               0.1,
+		MinRetriesPerSecond: 1,
+		Window:              time.Second,
+		now:                 func() time.Time { return now },
+	}
+
+	for range 20 {
+		budget.recordRequest("target")
+	}
+
+	// 0.1 * 20 requests + 1 retry per second
+	assert.True(t, budget.tryRetry("target"))
+	assert.True(t, budget.tryRetry("target"))
+	assert.True(t, budget.tryRetry("target"))
+	assert.False(t, budget.tryRetry("target"))
+
+	t.Run("budget is per target", func(t *testing.T) {
+		assert.True(t, budget.tryRetry("other-target"))
+	})
+
+	t.Run("budget is restored after the window", func(t *testing.T) {
+		now = now.Add(time.Second)
+		assert.True(t, budget.tryRetry("target"))
+	})
+}
+
+func TestGRPCUnaryInterceptorOptions_retryableInvoke_budget(t *testing.T) {
+	o := &GRPCUnaryInterceptorOptions{
+		RetryCount:    5,
+		RetryInterval: time.Millisecond,
+		RetryPolicy: &GRPCRetryPolicy{
+			Budget: &GRPCRetryBudget{Ratio: 0.1, MinRetriesPerSecond: 1, Window: time.Minute},
+		},
+	}
+	failures := []codes.Code{codes.Unavailable, codes.Unavailable, codes.Unavailable, codes.Unavailable, codes.Unavailable}
+
+	// the budget allows 0.1 * 1 request + 60 retries
+	calls := 0
+	_ = o.retryableInvoke(context.Background(), "/pkg.Service/Method", nil, nil, nil, newTrailerInvoker(&calls, nil, failures...))
+	assert.Equal(t, 5, calls)
+
+	o.RetryPolicy.Budget = &GRPCRetryBudget{Ratio: 0.1, MinRetriesPerSecond: 1, Window: time.Second}
+
+	// the budget allows 0.1 * 1 request + 1 retry
+	calls = 0
+	err := o.retryableInvoke(context.Background(), "/pkg.Service/Method", nil, nil, nil, newTrailerInvoker(&calls, nil, failures...))
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, 3, calls)
+
+	// the budget allows 0.1 * 2 requests + 1 retry, and it's already used
+	calls = 0
+	err = o.retryableInvoke(context.Background(), "/pkg.Service/Method", nil, nil, nil, newTrailerInvoker(&calls, nil, failures...))
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,94 @@
+package connect
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// newTrailerInvoker returns an invoker that fails with the given codes in order, and succeeds after that
+func newTrailerInvoker(calls *int, trailer metadata.MD, failures ...codes.Code) grpc.UnaryInvoker {
+	return func(_ context.Context, _ string, _, _ interface{}, _ *grpc.ClientConn, opts ...grpc.CallOption) error {
+		*calls++
+		for _, opt := range opts {
+			if t, ok := opt.(grpc.TrailerCallOption); ok {
+				*t.TrailerAddr = trailer
+			}
+		}
+		if *calls > len(failures) {
+			return nil
+		}
+		return status.Error(failures[*calls-1], "failed")
+	}
+}
+
+func TestGRPCUnaryInterceptorOptions_retryableInvoke(t *testing.T) {
+	t.Run("default policy only retries unavailable", func(t *testing.T) {
+		calls := 0
+		o := &GRPCUnaryInterceptorOptions{RetryCount: 3, RetryInterval: time.Millisecond}
+		err := o.retryableInvoke(context.Background(), "/pkg.Service/Method", nil, nil, nil, newTrailerInvoker(&calls, nil, codes.Unavailable, codes.Aborted))
+		assert.Equal(t, codes.Aborted, status.Code(err))
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("configured retryable codes", func(t *testing.T) {
+		calls := 0
+		o := &GRPCUnaryInterceptorOptions{
+			RetryCount:    3,
+			RetryInterval: time.Millisecond,
+			RetryPolicy: &GRPCRetryPolicy{
+				RetryableCodes: []codes.Code{codes.ResourceExhausted, codes.Aborted},
+				MaxBackoff:     2 * time.Millisecond,
+				Jitter:         true,
+			},
+		}
+		err := o.retryableInvoke(context.Background(), "/pkg.Service/Method", nil, nil, nil, newTrailerInvoker(&calls, nil, codes.ResourceExhausted, codes.Aborted))
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("stop after retry count", func(t *testing.T) {
+		calls := 0
+		o := &GRPCUnaryInterceptorOptions{RetryCount: 2, RetryInterval: time.Millisecond}
+		err := o.retryableInvoke(context.Background(), "/pkg.Service/Method", nil, nil, nil, newTrailerInvoker(&calls, nil, codes.Unavailable, codes.Unavailable, codes.Unavailable))
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("server pushback stops retry", func(t *testing.T) {
+		calls := 0
+		o := &GRPCUnaryInterceptorOptions{RetryCount: 3, RetryInterval: time.Millisecond}
+		err := o.retryableInvoke(context.Background(), "/pkg.Service/Method", nil, nil, nil, newTrailerInvoker(&calls, metadata.Pairs(retryPushbackKey, "-1"), codes.Unavailable))
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("per attempt timeout", func(t *testing.T) {
+		o := &GRPCUnaryInterceptorOptions{RetryCount: 1, RetryPolicy: &GRPCRetryPolicy{PerAttemptTimeout: time.Millisecond}}
+		err := o.retryableInvoke(context.Background(), "/pkg.Service/Method", nil, nil, nil, func(ctx context.Context, _ string, _, _ interface{}, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+			<-ctx.Done()
+			return status.FromContextError(ctx.Err()).Err()
+		})
+		assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	})
+}
+
+func Test_retryPushback(t *testing.T) {
+	_, ok := retryPushback(nil)
+	assert.False(t, ok)
+
+	delay, ok := retryPushback(metadata.Pairs(retryPushbackKey, "150"))
+	require.True(t, ok)
+	assert.Equal(t, 150*time.Millisecond, delay)
+
+	delay, ok = retryPushback(metadata.Pairs(retryPushbackKey, "invalid"))
+	require.True(t, ok)
+	assert.Negative(t, delay)
+}
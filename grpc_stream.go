@@ -0,0 +1,358 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/kumparan/go-utils"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"go.opentelemetry.io/otel/baggage"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewStreamClientInterceptor creates the StreamClientInterceptor, returns an error when the options are invalid
+func NewStreamClientInterceptor(opts *GRPCUnaryInterceptorOptions) (grpc.StreamClientInterceptor, error) {
+	o := applyGRPCUnaryInterceptorOptions(opts)
+	if err := o.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid gRPC client interceptor options: %w", err)
+	}
+	return streamClientInterceptor(o), nil
+}
+
+// StreamClientInterceptor wrapper with retry, timeout, open telemetry, and metadata logging for streaming calls.
+// Timeout and retry only apply to the stream establishment, an established stream lives until it's finished
+// or the parent context is done.
+// The invalid options are only logged, use NewStreamClientInterceptor to get the error.
+func StreamClientInterceptor(opts *GRPCUnaryInterceptorOptions) grpc.StreamClientInterceptor {
+	o := applyGRPCUnaryInterceptorOptions(opts)
+	if err := o.Validate(); err != nil {
+		logrus.Error("invalid gRPC client interceptor options: ", err)
+	}
+	return streamClientInterceptor(o)
+}
+
+func streamClientInterceptor(o *GRPCUnaryInterceptorOptions) grpc.StreamClientInterceptor {
+	metrics := newRPCMetrics(o.UseMetrics, "rpc.client.duration")
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if incomingMD, ok := metadata.FromIncomingContext(ctx); ok {
+			existingOutgoingMD, _ := metadata.FromOutgoingContext(ctx)
+			ctx = metadata.NewOutgoingContext(ctx, metadata.Join(existingOutgoingMD, incomingMD))
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, "caller", utils.MyCaller(5))
+
+		return o.forMethod(method).retryableNewClientStream(ctx, metrics, desc, cc, method, streamer, opts...)
+	}
+}
+
+// retryableNewClientStream establishes the stream with the retry policy and the retry budget of the unary calls.
+// The server pushback is not honored because the trailer of a failed establishment isn't available.
+func (o *GRPCUnaryInterceptorOptions) retryableNewClientStream(ctx context.Context, metrics *rpcMetrics, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	policy := o.retryPolicy()
+	b := policy.backoff(o.RetryInterval)
+
+	var target string
+	if cc != nil {
+		target = cc.Target()
+	}
+	if policy.Budget != nil {
+		policy.Budget.recordRequest(target)
+	}
+
+	for attempt := 1; ; attempt++ {
+		stream, err := o.newClientStream(ctx, metrics, desc, cc, method, streamer, opts...)
+		if err == nil || attempt >= o.RetryCount || !policy.isRetryable(err) {
+			return stream, err
+		}
+
+		if policy.Budget != nil && !policy.Budget.tryRetry(target) {
+			logrus.WithFields(logrus.Fields{
+				"target":  target,
+				"method":  method,
+				"attempt": attempt,
+			}).Warn("retry budget exhausted, stop retrying")
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(b.Duration()):
+		}
+	}
+}
+
+func (o *GRPCUnaryInterceptorOptions) newClientStream(ctx context.Context, metrics *rpcMetrics, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	// the stream context can't have a deadline because it will bound the whole stream lifetime,
+	// so cancel it manually when the establishment takes longer than the timeout
+	ctx, cancel := context.WithCancel(ctx)
+	establishTimer := time.AfterFunc(o.Timeout, cancel)
+
+	var span trace.Span
+	if o.UseOpenTelemetry {
+		requestMetadata, _ := metadata.FromOutgoingContext(ctx)
+		metadataCopy := requestMetadata.Copy()
+		tracer := newConfig().TracerProvider.Tracer(
+			instrumentationName,
+		)
+
+		name, attr := spanInfo(method, cc.Target())
+		ctx, span = tracer.Start(
+			ctx,
+			name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attr...),
+		)
+
+		inject(ctx, &metadataCopy)
+		ctx = metadata.NewOutgoingContext(ctx, metadataCopy)
+	}
+
+	stream := &wrappedClientStream{
+		ctx:     ctx,
+		span:    span,
+		cancel:  cancel,
+		desc:    desc,
+		method:  method,
+		begin:   time.Now(),
+		metrics: metrics,
+	}
+
+	s, err := streamer(ctx, desc, cc, method, opts...)
+	if !establishTimer.Stop() {
+		err = status.Errorf(codes.DeadlineExceeded, "stream establishment exceeds timeout %s", o.Timeout)
+	}
+	if err != nil {
+		stream.finish(err)
+		return nil, err
+	}
+
+	stream.ClientStream = s
+	// the caller may stop reading before the end of the stream, release the stream once its context is done
+	context.AfterFunc(ctx, func() {
+		stream.finish(status.FromContextError(ctx.Err()).Err())
+	})
+	return stream, nil
+}
+
+// wrappedClientStream wraps grpc.ClientStream to trace each message
+// and to release the stream resources once the stream is finished.
+// The stream is finished when RecvMsg returns an error or io.EOF, when SendMsg or Header fails,
+// or when the context of the stream is done, e.g. the caller stops reading and cancels the context.
+type wrappedClientStream struct {
+	grpc.ClientStream
+
+	ctx    context.Context
+	span   trace.Span
+	cancel context.CancelFunc
+	desc   *grpc.StreamDesc
+
+	method  string
+	begin   time.Time
+	metrics *rpcMetrics
+
+	// SendMsg and RecvMsg are never called concurrently with themselves,
+	// so each counter is only touched by a single goroutine
+	sentMessageID     int
+	receivedMessageID int
+
+	finishOnce sync.Once
+}
+
+// SendMsg sends the message and records it as a SENT event
+func (s *wrappedClientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	switch {
+	case err == nil:
+		s.sentMessageID++
+		if s.span != nil {
+			messageSent.Event(s.ctx, s.sentMessageID, m)
+		}
+	case !errors.Is(err, io.EOF): // io.EOF means the real status must be taken from RecvMsg
+		s.finish(err)
+	}
+
+	return err
+}
+
+// RecvMsg receives the message and records it as a RECEIVED event
+func (s *wrappedClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == nil:
+		s.receivedMessageID++
+		if s.span != nil {
+			messageReceived.Event(s.ctx, s.receivedMessageID, m)
+		}
+		if !s.desc.ServerStreams { // client streaming only receives a single response
+			s.finish(nil)
+		}
+	case errors.Is(err, io.EOF):
+		s.finish(nil)
+	default:
+		s.finish(err)
+	}
+
+	return err
+}
+
+// Header returns the header metadata and finishes the stream if it's failed
+func (s *wrappedClientStream) Header() (metadata.MD, error) {
+	md, err := s.ClientStream.Header()
+	if err != nil {
+		s.finish(err)
+	}
+
+	return md, err
+}
+
+func (s *wrappedClientStream) finish(err error) {
+	s.finishOnce.Do(func() {
+		if s.span != nil {
+			setSpanStatus(s.span, err)
+			s.span.End()
+		}
+		s.metrics.record(s.ctx, s.method, s.begin, err)
+		s.cancel()
+	})
+}
+
+// setSpanStatus sets the span status and the gRPC status code attribute from the given error
+func setSpanStatus(span trace.Span, err error) {
+	if err == nil {
+		span.SetAttributes(statusCodeAttr(codes.OK))
+		return
+	}
+
+	s, _ := status.FromError(err)
+	span.SetStatus(otelcodes.Error, s.Message())
+	span.SetAttributes(statusCodeAttr(s.Code()))
+}
+
+// NewStreamServerInterceptor creates the StreamServerInterceptor, returns an error when the options are invalid
+func NewStreamServerInterceptor(opts *GRPCUnaryInterceptorOptions, redisClient *redis.Client) (grpc.StreamServerInterceptor, error) {
+	o := applyGRPCServerInterceptorOptions(opts)
+	if err := o.validateServer(); err != nil {
+		return nil, fmt.Errorf("invalid gRPC server interceptor options: %w", err)
+	}
+	return streamServerInterceptor(o, redisClient), nil
+}
+
+// StreamServerInterceptor wrapper with panic recovery, open telemetry and rate limiter for streaming calls.
+// The rate limiter is checked once when the stream is opened, the default options are used when opts is nil.
+// The invalid options are only logged, use NewStreamServerInterceptor to get the error.
+func StreamServerInterceptor(opts *GRPCUnaryInterceptorOptions, redisClient *redis.Client) grpc.StreamServerInterceptor {
+	o := applyGRPCServerInterceptorOptions(opts)
+	if err := o.validateServer(); err != nil {
+		logrus.Error("invalid gRPC server interceptor options: ", err)
+	}
+	return streamServerInterceptor(o, redisClient)
+}
+
+func streamServerInterceptor(opts *GRPCUnaryInterceptorOptions, redisClient *redis.Client) grpc.StreamServerInterceptor {
+	metrics := newRPCMetrics(opts.UseMetrics, "rpc.server.duration")
+	rateLimiterMetrics := newRateLimiterMetrics(opts.UseMetrics, "grpc")
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		begin := time.Now()
+		ctx := ss.Context()
+		panicked := true // default value, if not panic, this will be changed to false before the defer func called
+
+		var span trace.Span
+		defer func() {
+			// recover before ending the span, so the span has the status of the recovered error
+			if r := recover(); r != nil || panicked {
+				err = recoverFrom(ctx, r, opts.RecoveryHandlerFunc)
+				if span != nil {
+					setSpanStatus(span, err)
+				}
+				metrics.record(ctx, info.FullMethod, begin, err)
+			}
+			if span != nil {
+				span.End()
+			}
+		}()
+
+		if opts.UseOpenTelemetry {
+			requestMetadata, _ := metadata.FromIncomingContext(ctx)
+			metadataCopy := requestMetadata.Copy()
+
+			bags, spanCtx := extract(ctx, &metadataCopy)
+			ctx = baggage.ContextWithBaggage(ctx, bags)
+
+			tracer := newConfig().TracerProvider.Tracer(
+				instrumentationName,
+			)
+
+			name, attr := spanInfo(info.FullMethod, peerFromCtx(ctx))
+			ctx, span = tracer.Start(
+				trace.ContextWithRemoteSpanContext(ctx, spanCtx),
+				name,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(attr...),
+			)
+		}
+
+		if opts.RateLimiter != nil && redisClient != nil && isRequestRateLimited(ctx, redisClient, opts.RateLimiter, rateLimiterMetrics) {
+			err = status.Errorf(codes.ResourceExhausted, "too many requests")
+		} else {
+			err = handler(srv, &wrappedServerStream{ServerStream: ss, ctx: ctx, traced: span != nil})
+		}
+
+		if span != nil {
+			setSpanStatus(span, err)
+		}
+		metrics.record(ctx, info.FullMethod, begin, err)
+
+		panicked = false
+		return err
+	}
+}
+
+// wrappedServerStream wraps grpc.ServerStream to pass the traced context to the handler
+// and to trace each message
+type wrappedServerStream struct {
+	grpc.ServerStream
+
+	ctx    context.Context
+	traced bool
+
+	sentMessageID     int
+	receivedMessageID int
+}
+
+// Context returns the context with the extracted trace context and baggage
+func (s *wrappedServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// SendMsg sends the message and records it as a SENT event
+func (s *wrappedServerStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil && s.traced {
+		s.sentMessageID++
+		messageSent.Event(s.ctx, s.sentMessageID, m)
+	}
+
+	return err
+}
+
+// RecvMsg receives the message and records it as a RECEIVED event
+func (s *wrappedServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil && s.traced {
+		s.receivedMessageID++
+		messageReceived.Event(s.ctx, s.receivedMessageID, m)
+	}
+
+	return err
+}
@@ -0,0 +1,225 @@
+package connect
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type mockClientStream struct {
+	grpc.ClientStream
+	ctx      context.Context
+	received int
+	maxRecv  int
+}
+
+func (m *mockClientStream) Context() context.Context { return m.ctx }
+
+func (m *mockClientStream) SendMsg(_ interface{}) error { return nil }
+
+func (m *mockClientStream) RecvMsg(_ interface{}) error {
+	if m.received >= m.maxRecv {
+		return io.EOF
+	}
+	m.received++
+	return nil
+}
+
+func (m *mockClientStream) Header() (metadata.MD, error) { return metadata.MD{}, nil }
+
+func setupSpanRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previousProvider := otel.GetTracerProvider()
+	previousPropagator := otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	return recorder
+}
+
+func TestStreamClientInterceptor(t *testing.T) {
+	cc, err := grpc.NewClient("passthrough:///localhost:0", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer cc.Close()
+
+	desc := &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}
+
+	t.Run("retry on unavailable", func(t *testing.T) {
+		calls := 0
+		interceptor := StreamClientInterceptor(&GRPCUnaryInterceptorOptions{RetryCount: 3, RetryInterval: time.Millisecond})
+		_, err := interceptor(context.Background(), desc, cc, "/pkg.Service/Method", func(_ context.Context, _ *grpc.StreamDesc, _ *grpc.ClientConn, _ string, _ ...grpc.CallOption) (grpc.ClientStream, error) {
+			calls++
+			return nil, status.Error(codes.Unavailable, "unavailable")
+		})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("no retry on other errors", func(t *testing.T) {
+		calls := 0
+		interceptor := StreamClientInterceptor(&GRPCUnaryInterceptorOptions{RetryCount: 3, RetryInterval: time.Millisecond})
+		_, err := interceptor(context.Background(), desc, cc, "/pkg.Service/Method", func(_ context.Context, _ *grpc.StreamDesc, _ *grpc.ClientConn, _ string, _ ...grpc.CallOption) (grpc.ClientStream, error) {
+			calls++
+			return nil, status.Error(codes.InvalidArgument, "invalid")
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("retry with the retry policy and budget", func(t *testing.T) {
+		calls := 0
+		interceptor := StreamClientInterceptor(&GRPCUnaryInterceptorOptions{
+			RetryCount:    5,
+			RetryInterval: time.Millisecond,
+			RetryPolicy: &GRPCRetryPolicy{
+				RetryableCodes: []codes.Code{codes.ResourceExhausted},
+				Budget:         &GRPCRetryBudget{Ratio: 0.1, MinRetriesPerSecond: 1, Window: time.Second},
+			},
+		})
+		_, err := interceptor(context.Background(), desc, cc, "/pkg.Service/Method", func(_ context.Context, _ *grpc.StreamDesc, _ *grpc.ClientConn, _ string, _ ...grpc.CallOption) (grpc.ClientStream, error) {
+			calls++
+			return nil, status.Error(codes.ResourceExhausted, "exhausted")
+		})
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		assert.Equal(t, 3, calls, "the budget allows 0.1 * 1 request + 1 retry")
+	})
+
+	t.Run("finish the stream when the context is done", func(t *testing.T) {
+		recorder := setupSpanRecorder(t)
+		interceptor := StreamClientInterceptor(&GRPCUnaryInterceptorOptions{UseOpenTelemetry: true})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		var streamCtx context.Context
+		stream, err := interceptor(ctx, desc, cc, "/pkg.Service/Method", func(ctx context.Context, _ *grpc.StreamDesc, _ *grpc.ClientConn, _ string, _ ...grpc.CallOption) (grpc.ClientStream, error) {
+			streamCtx = ctx
+			return &mockClientStream{ctx: ctx, maxRecv: 2}, nil
+		})
+		require.NoError(t, err)
+		require.NoError(t, stream.RecvMsg(nil))
+		assert.Empty(t, recorder.Ended())
+
+		// stop reading before the end of the stream
+		cancel()
+		assert.Eventually(t, func() bool { return len(recorder.Ended()) == 1 }, time.Second, time.Millisecond)
+		assert.Equal(t, otelcodes.Error, recorder.Ended()[0].Status().Code)
+		assert.ErrorIs(t, streamCtx.Err(), context.Canceled)
+	})
+
+	t.Run("trace messages with incrementing ids", func(t *testing.T) {
+		recorder := setupSpanRecorder(t)
+		interceptor := StreamClientInterceptor(&GRPCUnaryInterceptorOptions{UseOpenTelemetry: true})
+
+		var streamCtx context.Context
+		stream, err := interceptor(context.Background(), desc, cc, "/pkg.Service/Method", func(ctx context.Context, _ *grpc.StreamDesc, _ *grpc.ClientConn, _ string, _ ...grpc.CallOption) (grpc.ClientStream, error) {
+			streamCtx = ctx
+			return &mockClientStream{ctx: ctx, maxRecv: 2}, nil
+		})
+		require.NoError(t, err)
+
+		md, _ := metadata.FromOutgoingContext(streamCtx)
+		assert.NotEmpty(t, md.Get("traceparent"))
+		assert.NotEmpty(t, md.Get("caller"))
+
+		require.NoError(t, stream.SendMsg("first"))
+		require.NoError(t, stream.SendMsg("second"))
+		require.NoError(t, stream.RecvMsg(nil))
+		require.NoError(t, stream.RecvMsg(nil))
+		assert.ErrorIs(t, stream.RecvMsg(nil), io.EOF)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		assert.Equal(t, "pkg.Service/Method", spans[0].Name())
+
+		var sentIDs, receivedIDs []int64
+		for _, event := range spans[0].Events() {
+			var messageType string
+			var id int64
+			for _, attr := range event.Attributes {
+				switch attr.Key {
+				case "message.type":
+					messageType = attr.Value.AsString()
+				case "message.id":
+					id = attr.Value.AsInt64()
+				}
+			}
+			if messageType == "SENT" {
+				sentIDs = append(sentIDs, id)
+			} else {
+				receivedIDs = append(receivedIDs, id)
+			}
+		}
+		assert.Equal(t, []int64{1, 2}, sentIDs)
+		assert.Equal(t, []int64{1, 2}, receivedIDs)
+		assert.ErrorIs(t, streamCtx.Err(), context.Canceled)
+	})
+}
+
+type mockServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (m *mockServerStream) Context() context.Context { return m.ctx }
+
+func (m *mockServerStream) SendMsg(_ interface{}) error { return nil }
+
+func (m *mockServerStream) RecvMsg(_ interface{}) error { return nil }
+
+func TestStreamServerInterceptor(t *testing.T) {
+	info := &grpc.StreamServerInfo{FullMethod: "/pkg.Service/Method", IsServerStream: true}
+
+	t.Run("recover from panic", func(t *testing.T) {
+		recorder := setupSpanRecorder(t)
+		interceptor := StreamServerInterceptor(&GRPCUnaryInterceptorOptions{UseOpenTelemetry: true}, nil)
+		err := interceptor(nil, &mockServerStream{ctx: context.Background()}, info, func(_ interface{}, _ grpc.ServerStream) error {
+			panic("boom")
+		})
+		assert.Equal(t, codes.Internal, status.Code(err))
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		assert.Equal(t, otelcodes.Error, spans[0].Status().Code)
+	})
+
+	t.Run("handler context carries the extracted trace", func(t *testing.T) {
+		recorder := setupSpanRecorder(t)
+		interceptor := StreamServerInterceptor(&GRPCUnaryInterceptorOptions{UseOpenTelemetry: true}, nil)
+
+		traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+			"traceparent", "00-"+traceID+"-00f067aa0ba902b7-01",
+		))
+
+		err := interceptor(nil, &mockServerStream{ctx: ctx}, info, func(_ interface{}, stream grpc.ServerStream) error {
+			assert.Equal(t, traceID, trace.SpanContextFromContext(stream.Context()).TraceID().String())
+			require.NoError(t, stream.RecvMsg(nil))
+			require.NoError(t, stream.SendMsg(nil))
+			require.NoError(t, stream.SendMsg(nil))
+			return nil
+		})
+		require.NoError(t, err)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		assert.Equal(t, traceID, spans[0].Parent().TraceID().String())
+		assert.Len(t, spans[0].Events(), 3)
+	})
+}
@@ -18,6 +18,10 @@ const (
 	instrumentationName = "github.com/kumparan/go-connect"
 	// grpcStatusCodeKey is convention for numeric status code of a gRPC request.
 	grpcStatusCodeKey = attribute.Key("rpc.grpc.status_code")
+	// attemptKey is the attempt number of a retried gRPC request, starting from 1.
+	attemptKey = attribute.Key("attempt")
+	// hedgeKey is the hedge number of a hedged gRPC request, 0 is the original attempt.
+	hedgeKey = attribute.Key("hedge")
 	// defaultMessageID is default id for event message
 	defaultMessageID = 1
 
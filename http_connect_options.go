@@ -6,8 +6,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 
-	"github.com/afex/hystrix-go/hystrix"
 	log "github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
@@ -52,20 +53,47 @@ func NewTransport(connectionName string, opts ...Option) *Transport {
 	return t
 }
 
-// CircuitBreakerTransport wraps http.RoundTripper with circuit breaker support using hystrix.
+// CircuitBreakerTransport wraps http.RoundTripper with circuit breaker support, default is HystrixCircuitBreaker.
 // 5xx responses and network errors trip the circuit; 4xx responses are returned without affecting circuit state.
 type CircuitBreakerTransport struct {
 	commandName string
 	rt          http.RoundTripper
+	breaker     CircuitBreaker
+	fallback    HTTPFallbackFunc
 }
 
-// RoundTrip executes the HTTP request inside a hystrix circuit breaker.
+// HTTPFallbackFunc returns the response when the request is rejected by the circuit breaker,
+// err is either ErrCircuitOpen or ErrMaxConcurrency
+type HTTPFallbackFunc func(req *http.Request, err error) (*http.Response, error)
+
+// NewServiceUnavailableFallback returns HTTPFallbackFunc responding 503 Service Unavailable with the Retry-After header
+func NewServiceUnavailableFallback(retryAfter time.Duration) HTTPFallbackFunc {
+	return func(req *http.Request, _ error) (*http.Response, error) {
+		header := http.Header{}
+		header.Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+		return &http.Response{
+			Status:     http.StatusText(http.StatusServiceUnavailable),
+			StatusCode: http.StatusServiceUnavailable,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     header,
+			Body:       http.NoBody,
+			Request:    req,
+		}, nil
+	}
+}
+
+// RoundTrip executes the HTTP request inside a circuit breaker.
 func (t *CircuitBreakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	breaker := t.breaker
+	if breaker == nil {
+		breaker = NewHystrixCircuitBreaker()
+	}
+
 	success := make(chan *http.Response, 1)
 	ignoredResp := make(chan *http.Response, 1)
-	cb, _, _ := hystrix.GetCircuit(t.commandName)
-	log.Info("CIRCUIT BREAKER IS_OPEN:", cb.IsOpen())
-	errC := hystrix.GoC(req.Context(), t.commandName, func(ctx context.Context) error {
+	err := breaker.Execute(req.Context(), t.commandName, func(ctx context.Context) error {
 		resp, err := t.rt.RoundTrip(req.WithContext(ctx))
 		if err != nil {
 			return err // network error should trips circuit
@@ -80,15 +108,20 @@ func (t *CircuitBreakerTransport) RoundTrip(req *http.Request) (*http.Response,
 		}
 		success <- resp
 		return nil
-	}, nil)
+	})
+	if err != nil {
+		recordShortCircuit(req.Context(), t.commandName, err)
+		if t.fallback != nil && isShortCircuited(err) {
+			return t.fallback(req, err)
+		}
+		return nil, err
+	}
 
 	select {
 	case resp := <-success:
 		return resp, nil
-	case resp := <-ignoredResp:
-		return resp, nil
-	case err := <-errC:
-		return nil, err
+	default:
+		return <-ignoredResp, nil
 	}
 }
 
@@ -1,6 +1,8 @@
 package connect
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -184,4 +186,60 @@ func TestCircuitBreakerTransport_RoundTrip(t *testing.T) {
 		assert.Error(t, err)
 		assert.ErrorContains(t, err, "circuit open")
 	})
+
+	t.Run("custom circuit breaker", func(t *testing.T) {
+		mock := &mockRoundTripper{statusCode: 500}
+		cb := &CircuitBreakerTransport{
+			commandName: t.Name(),
+			rt:          mock,
+			breaker:     newTestSlidingWindowCircuitBreaker(t, &SlidingWindowCircuitBreakerOptions{ConsecutiveFailures: 1}),
+		}
+
+		firstResp, err := cb.RoundTrip(makeReq())
+		if firstResp != nil {
+			firstResp.Body.Close()
+		}
+		assert.ErrorContains(t, err, "server error: 500")
+
+		// the circuit is open synchronously, no need to wait
+		mock.statusCode = 200
+		secondResp, err := cb.RoundTrip(makeReq())
+		if secondResp != nil {
+			secondResp.Body.Close()
+		}
+		assert.ErrorIs(t, err, ErrCircuitOpen)
+	})
+
+	t.Run("fallback when circuit open", func(t *testing.T) {
+		breaker := newTestSlidingWindowCircuitBreaker(t, &SlidingWindowCircuitBreakerOptions{ConsecutiveFailures: 1})
+		_ = breaker.Execute(context.Background(), t.Name(), func(_ context.Context) error { return errors.New("failed") })
+
+		cb := &CircuitBreakerTransport{
+			commandName: t.Name(),
+			rt:          &mockRoundTripper{statusCode: 200},
+			breaker:     breaker,
+			fallback:    NewServiceUnavailableFallback(30 * time.Second),
+		}
+
+		resp, err := cb.RoundTrip(makeReq())
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Equal(t, "30", resp.Header.Get("Retry-After"))
+	})
+
+	t.Run("fallback is not called on server error", func(t *testing.T) {
+		cb := &CircuitBreakerTransport{
+			commandName: t.Name(),
+			rt:          &mockRoundTripper{statusCode: 500},
+			breaker:     newTestSlidingWindowCircuitBreaker(t, nil),
+			fallback:    NewServiceUnavailableFallback(time.Second),
+		}
+
+		resp, err := cb.RoundTrip(makeReq())
+		if resp != nil {
+			resp.Body.Close()
+		}
+		assert.ErrorContains(t, err, "server error: 500")
+	})
 }
@@ -2,10 +2,11 @@ package connect
 
 import (
 	"crypto/tls"
+	"fmt"
 	"net/http"
 	"time"
 
-	"github.com/afex/hystrix-go/hystrix"
+	log "github.com/sirupsen/logrus"
 )
 
 // HTTPConnectionOptions options for the http connection
@@ -18,6 +19,15 @@ type HTTPConnectionOptions struct {
 	CircuitBreakerConfig  *CircuitSetting
 	EnableKeepAlives      bool
 	Name                  string
+
+	// CircuitBreaker used when UseCircuitBreaker is true, default is HystrixCircuitBreaker configured by CircuitBreakerConfig
+	CircuitBreaker CircuitBreaker
+
+	// CircuitBreakerFallback returns the response when the request is rejected by the circuit breaker
+	CircuitBreakerFallback HTTPFallbackFunc
+
+	// UseMetrics flag if the connection will record http.client.duration metric
+	UseMetrics bool
 }
 
 var defaultHTTPConnectionOptions = &HTTPConnectionOptions{
@@ -30,37 +40,71 @@ var defaultHTTPConnectionOptions = &HTTPConnectionOptions{
 	Name:                  "HTTPRequest",
 }
 
-// NewHTTPConnection new http client
+// NewHTTPConnectionE new http client, returns an error when the options are invalid
+func NewHTTPConnectionE(opt *HTTPConnectionOptions) (*http.Client, error) {
+	options := applyHTTPConnectionOptions(opt)
+	if err := options.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid HTTP connection options: %w", err)
+	}
+	return newHTTPConnection(options), nil
+}
+
+// NewHTTPConnection new http client, the invalid options are only logged, use NewHTTPConnectionE to get the error
 func NewHTTPConnection(opt *HTTPConnectionOptions) *http.Client {
 	options := applyHTTPConnectionOptions(opt)
+	if err := options.Validate(); err != nil {
+		log.Error("invalid HTTP connection options: ", err)
+	}
+	return newHTTPConnection(options)
+}
 
+func newHTTPConnection(options *HTTPConnectionOptions) *http.Client {
 	var rt http.RoundTripper = &http.Transport{
 		TLSHandshakeTimeout: options.TLSHandshakeTimeout,
 		TLSClientConfig:     &tls.Config{InsecureSkipVerify: options.TLSInsecureSkipVerify}, //nolint:gosec
 		DisableKeepAlives:   !options.EnableKeepAlives,
 	}
 
+	if options.UseMetrics {
+		rt = newMetricsTransport(rt)
+	}
+
 	if options.UseOpenTelemetry {
 		rt = NewTransport(options.Name, WithRoundTripper(rt))
 	}
 
 	if options.UseCircuitBreaker {
-		rt = &CircuitBreakerTransport{commandName: options.Name, rt: rt}
-		if options.CircuitBreakerConfig == nil {
-			options.CircuitBreakerConfig = &defaultCircuitBreakerConfig
+		breaker := options.CircuitBreaker
+		if breaker == nil {
+			if options.CircuitBreakerConfig == nil {
+				options.CircuitBreakerConfig = &defaultCircuitBreakerConfig
+			}
+			hystrixBreaker := NewHystrixCircuitBreaker()
+			hystrixBreaker.Configure(options.Name, options.CircuitBreakerConfig)
+			breaker = hystrixBreaker
+		}
+		rt = &CircuitBreakerTransport{
+			commandName: options.Name,
+			rt:          rt,
+			breaker:     breaker,
+			fallback:    options.CircuitBreakerFallback,
 		}
-		hystrix.ConfigureCommand(options.Name, hystrix.CommandConfig{
-			Timeout:                opt.CircuitBreakerConfig.Timeout,
-			MaxConcurrentRequests:  opt.CircuitBreakerConfig.MaxConcurrentRequests,
-			RequestVolumeThreshold: opt.CircuitBreakerConfig.RequestVolumeThreshold,
-			SleepWindow:            opt.CircuitBreakerConfig.SleepWindow,
-			ErrorPercentThreshold:  opt.CircuitBreakerConfig.ErrorPercentThreshold,
-		})
 	}
 
 	return &http.Client{Timeout: options.Timeout, Transport: rt}
 }
 
+// Validate returns the joined errors of every invalid field
+func (o *HTTPConnectionOptions) Validate() error {
+	v := &optionsValidator{}
+	v.check(o.TLSHandshakeTimeout >= 0, "TLSHandshakeTimeout", "must not be negative")
+	v.check(o.Timeout >= 0, "Timeout", "must not be negative")
+	if o.CircuitBreakerConfig != nil {
+		v.nested("CircuitBreakerConfig", o.CircuitBreakerConfig.Validate())
+	}
+	return v.err()
+}
+
 func applyHTTPConnectionOptions(opt *HTTPConnectionOptions) *HTTPConnectionOptions {
 	if opt != nil {
 		return opt
@@ -0,0 +1,267 @@
+package connect
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/kumparan/go-connect/internal"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"google.golang.org/grpc/status"
+)
+
+// the metrics are recorded to the global meter provider, which drops everything until otel.SetMeterProvider is called
+
+// meter returns the meter of this instrumentation from the global meter provider
+func meter() metric.Meter {
+	return otel.GetMeterProvider().Meter(instrumentationName)
+}
+
+// rpcMetrics records the duration of gRPC calls, nil means the metrics are disabled
+type rpcMetrics struct {
+	duration metric.Float64Histogram
+}
+
+// newRPCMetrics returns nil when disabled, name is either rpc.client.duration or rpc.server.duration
+func newRPCMetrics(enabled bool, name string) *rpcMetrics {
+	if !enabled {
+		return nil
+	}
+
+	duration, err := meter().Float64Histogram(
+		name,
+		metric.WithDescription("Duration of the gRPC calls"),
+		metric.WithUnit("ms"),
+	)
+	if err != nil {
+		log.Errorf("failed to create %s metric: %v", name, err)
+		return nil
+	}
+
+	return &rpcMetrics{duration: duration}
+}
+
+// record records the duration of the call since begin, by method and status code
+func (m *rpcMetrics) record(ctx context.Context, fullMethod string, begin time.Time, err error) {
+	if m == nil {
+		return
+	}
+
+	_, attrs := internal.ParseFullMethod(fullMethod)
+	attrs = append(attrs,
+		semconv.RPCSystemKey.String("grpc"),
+		statusCodeAttr(status.Code(err)),
+	)
+	m.duration.Record(ctx, durationMillis(begin), metric.WithAttributes(attrs...))
+}
+
+// metricsTransport records the duration of HTTP requests by host and status code
+type metricsTransport struct {
+	rt       http.RoundTripper
+	duration metric.Float64Histogram
+}
+
+// newMetricsTransport returns rt as is when the metric can't be created
+func newMetricsTransport(rt http.RoundTripper) http.RoundTripper {
+	duration, err := meter().Float64Histogram(
+		"http.client.duration",
+		metric.WithDescription("Duration of the HTTP requests"),
+		metric.WithUnit("ms"),
+	)
+	if err != nil {
+		log.Errorf("failed to create http.client.duration metric: %v", err)
+		return rt
+	}
+
+	return &metricsTransport{rt: rt, duration: duration}
+}
+
+// RoundTrip executes the request and records its duration
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	begin := time.Now()
+	resp, err := t.rt.RoundTrip(req)
+
+	statusCode := "error"
+	if resp != nil {
+		statusCode = strconv.Itoa(resp.StatusCode)
+	}
+	t.duration.Record(req.Context(), durationMillis(begin), metric.WithAttributes(
+		attribute.String("http.host", req.URL.Hostname()),
+		attribute.String("http.method", req.Method),
+		attribute.String("http.status_code", statusCode),
+	))
+
+	return resp, err
+}
+
+// registerRedigoPoolMetrics observes the redigo pool stats
+func registerRedigoPoolMetrics(pool *redigo.Pool, poolName string) error {
+	m := meter()
+	active, err := m.Int64ObservableGauge("redis.pool.active", metric.WithDescription("Number of connections in the pool, including the idle connections"))
+	if err != nil {
+		return err
+	}
+	idle, err := m.Int64ObservableGauge("redis.pool.idle", metric.WithDescription("Number of idle connections in the pool"))
+	if err != nil {
+		return err
+	}
+
+	attrs := metric.WithAttributes(attribute.String("pool.name", poolName))
+	_, err = m.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		stats := pool.Stats()
+		o.ObserveInt64(active, int64(stats.ActiveCount), attrs)
+		o.ObserveInt64(idle, int64(stats.IdleCount), attrs)
+		return nil
+	}, active, idle)
+	return err
+}
+
+// registerDBStatsMetrics observes sql.DBStats of the database returned by getDB, getDB is called on every collection
+// so the stats follow the reconnected database
+func registerDBStatsMetrics(dbSystem string, getDB func() (*sql.DB, error)) error {
+	m := meter()
+	open, err := m.Int64ObservableGauge("db.client.connections.open", metric.WithDescription("Number of established connections, both in use and idle"))
+	if err != nil {
+		return err
+	}
+	inUse, err := m.Int64ObservableGauge("db.client.connections.in_use", metric.WithDescription("Number of connections currently in use"))
+	if err != nil {
+		return err
+	}
+	idle, err := m.Int64ObservableGauge("db.client.connections.idle", metric.WithDescription("Number of idle connections"))
+	if err != nil {
+		return err
+	}
+	waitCount, err := m.Int64ObservableCounter("db.client.connections.wait_count", metric.WithDescription("Total number of connections waited for"))
+	if err != nil {
+		return err
+	}
+	waitDuration, err := m.Float64ObservableCounter("db.client.connections.wait_duration", metric.WithDescription("Total time blocked waiting for a new connection"), metric.WithUnit("ms"))
+	if err != nil {
+		return err
+	}
+
+	attrs := metric.WithAttributes(semconv.DBSystemKey.String(dbSystem))
+	_, err = m.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		db, err := getDB()
+		if err != nil || db == nil {
+			return nil // skip when the database is reconnecting
+		}
+
+		stats := db.Stats()
+		o.ObserveInt64(open, int64(stats.OpenConnections), attrs)
+		o.ObserveInt64(inUse, int64(stats.InUse), attrs)
+		o.ObserveInt64(idle, int64(stats.Idle), attrs)
+		o.ObserveInt64(waitCount, stats.WaitCount, attrs)
+		o.ObserveFloat64(waitDuration, float64(stats.WaitDuration)/float64(time.Millisecond), attrs)
+		return nil
+	}, open, inUse, idle, waitCount, waitDuration)
+	return err
+}
+
+// rateLimiterMetrics counts the decisions of a rate limiter, nil means the metrics are disabled
+type rateLimiterMetrics struct {
+	name     string
+	requests metric.Int64Counter
+}
+
+// newRateLimiterMetrics returns nil when disabled, name is the rate_limiter.name attribute
+func newRateLimiterMetrics(enabled bool, name string) *rateLimiterMetrics {
+	if !enabled {
+		return nil
+	}
+
+	requests, err := meter().Int64Counter(
+		"rate_limiter.requests",
+		metric.WithDescription("Number of requests checked by the rate limiter, by decision"),
+	)
+	if err != nil {
+		log.Errorf("failed to create rate_limiter.requests metric: %v", err)
+		return nil
+	}
+
+	return &rateLimiterMetrics{name: name, requests: requests}
+}
+
+// record counts the decision of the rate limiter
+func (m *rateLimiterMetrics) record(ctx context.Context, limited bool) {
+	if m == nil {
+		return
+	}
+
+	decision := "allowed"
+	if limited {
+		decision = "denied"
+	}
+	m.requests.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("rate_limiter.name", m.name),
+		attribute.String("rate_limiter.decision", decision),
+	))
+}
+
+// circuitBreakerMetrics counts the state changes and the rejected calls of the circuits, nil means the metrics can't be created
+type circuitBreakerMetrics struct {
+	stateChanges metric.Int64Counter
+	rejections   metric.Int64Counter
+}
+
+func newCircuitBreakerMetrics(enabled bool) *circuitBreakerMetrics {
+	if !enabled {
+		return nil
+	}
+
+	m := meter()
+	stateChanges, err := m.Int64Counter(
+		"circuit_breaker.state_changes",
+		metric.WithDescription("Number of state changes of the circuits, by the new state"),
+	)
+	if err != nil {
+		log.Errorf("failed to create circuit_breaker.state_changes metric: %v", err)
+		return nil
+	}
+	rejections, err := m.Int64Counter(
+		"circuit_breaker.rejections",
+		metric.WithDescription("Number of calls rejected by the circuits, by reason"),
+	)
+	if err != nil {
+		log.Errorf("failed to create circuit_breaker.rejections metric: %v", err)
+		return nil
+	}
+
+	return &circuitBreakerMetrics{stateChanges: stateChanges, rejections: rejections}
+}
+
+// recordStateChange counts the state change of the circuit
+func (m *circuitBreakerMetrics) recordStateChange(name string, to CircuitState) {
+	if m == nil {
+		return
+	}
+
+	m.stateChanges.Add(context.Background(), 1, metric.WithAttributes(
+		attribute.String("circuit.name", name),
+		attribute.String("circuit.state", to.String()),
+	))
+}
+
+// recordRejection counts the call rejected by the circuit, err is either ErrCircuitOpen or ErrMaxConcurrency
+func (m *circuitBreakerMetrics) recordRejection(ctx context.Context, name string, err error) {
+	if m == nil {
+		return
+	}
+
+	m.rejections.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("circuit.name", name),
+		attribute.String("circuit.reason", shortCircuitReason(err)),
+	))
+}
+
+func durationMillis(begin time.Time) float64 {
+	return float64(time.Since(begin)) / float64(time.Millisecond)
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
 
@@ -9,6 +10,9 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/redis/go-redis/v9"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 
 	"github.com/ulule/limiter/v3"
 	redisStore "github.com/ulule/limiter/v3/drivers/store/redis"
@@ -19,10 +23,32 @@ type RedisIPRateLimiter struct {
 	ipLimiter          *limiter.Limiter
 	excludedIPs        []string
 	excludedUserAgents []string
+	requests           metric.Int64Counter
+}
+
+// RedisIPRateLimiterOptions :nodoc:
+type RedisIPRateLimiterOptions struct {
+	ExcludedIPs        []string
+	ExcludedUserAgents []string
+
+	// flag if the limiter will count rate_limiter.requests metric
+	UseMetrics bool
 }
 
 // NewRedisIPRateLimiter initializes RedisIPRateLimiter
 func NewRedisIPRateLimiter(redisClient *redis.Client, rate limiter.Rate, excludedIPs []string, excludedUserAgents []string) (redisLimiter RedisIPRateLimiter, err error) {
+	return NewRedisIPRateLimiterWithOptions(redisClient, rate, &RedisIPRateLimiterOptions{
+		ExcludedIPs:        excludedIPs,
+		ExcludedUserAgents: excludedUserAgents,
+	})
+}
+
+// NewRedisIPRateLimiterWithOptions initializes RedisIPRateLimiter with options
+func NewRedisIPRateLimiterWithOptions(redisClient *redis.Client, rate limiter.Rate, opt *RedisIPRateLimiterOptions) (redisLimiter RedisIPRateLimiter, err error) {
+	if opt == nil {
+		opt = &RedisIPRateLimiterOptions{}
+	}
+
 	store, err := redisStore.NewStoreWithOptions(redisClient, limiter.StoreOptions{
 		Prefix: "rate-limiter:",
 	})
@@ -31,13 +57,27 @@ func NewRedisIPRateLimiter(redisClient *redis.Client, rate limiter.Rate, exclude
 	}
 
 	var formattedExcludedUserAgents []string
-	for _, v := range excludedUserAgents {
+	for _, v := range opt.ExcludedUserAgents {
 		formattedExcludedUserAgents = append(formattedExcludedUserAgents, strings.TrimSpace(strings.ToLower(v)))
 	}
+
+	var requests metric.Int64Counter
+	if opt.UseMetrics {
+		// the decisions are counted to the global meter provider
+		requests, err = otel.GetMeterProvider().Meter(instrumentationName).Int64Counter(
+			"rate_limiter.requests",
+			metric.WithDescription("Number of requests checked by the rate limiter, by decision"),
+		)
+		if err != nil {
+			return
+		}
+	}
+
 	return RedisIPRateLimiter{
 		ipLimiter:          limiter.New(store, rate),
-		excludedIPs:        excludedIPs,
+		excludedIPs:        opt.ExcludedIPs,
 		excludedUserAgents: formattedExcludedUserAgents,
+		requests:           requests,
 	}, nil
 }
 
@@ -61,6 +101,7 @@ func (r RedisIPRateLimiter) Limit() echo.MiddlewareFunc {
 				})
 			}
 
+			r.recordDecision(c.Request().Context(), limiterCtx.Reached)
 			if limiterCtx.Reached {
 				log.Printf("Too Many Requests from %s on %s", ip, c.Request().URL)
 				return c.JSON(http.StatusTooManyRequests, echo.Map{
@@ -74,3 +115,19 @@ func (r RedisIPRateLimiter) Limit() echo.MiddlewareFunc {
 	}
 
 }
+
+// recordDecision counts the rate limiter decision
+func (r RedisIPRateLimiter) recordDecision(ctx context.Context, limited bool) {
+	if r.requests == nil {
+		return
+	}
+
+	decision := "allowed"
+	if limited {
+		decision = "denied"
+	}
+	r.requests.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("rate_limiter.name", "echo"),
+		attribute.String("rate_limiter.decision", decision),
+	))
+}
@@ -2,169 +2,71 @@ package connect
 
 import (
 	"context"
-	"time"
+	"fmt"
 
-	"github.com/imdario/mergo"
-
-	"github.com/jpillora/backoff"
 	log "github.com/sirupsen/logrus"
-	"github.com/uptrace/opentelemetry-go-extra/otelgorm"
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
-	gormLogger "gorm.io/gorm/logger"
 )
 
-// MySQLConnectionOptions options for the  CockroachDB connection
-type MySQLConnectionOptions struct {
-	PingInterval      time.Duration
-	RetryAttempts     int
-	MaxIdleConns      int
-	MaxOpenConns      int
-	ConnMaxLifetime   time.Duration
-	LogLevel          string
-	UseOpenTelemetry  bool
-	PingTimeout       time.Duration
-	ReconnectCallback func(db *gorm.DB) // func will provide new *gorm.DB if current connection is broken and re-creation of new connection is succeeded.
-}
+// MySQLConnectionOptions options for the MySQL connection
+type MySQLConnectionOptions = SQLConnectionOptions
 
-var (
-	defaultMySQLConnectionOptions = &MySQLConnectionOptions{
-		PingInterval:     1 * time.Second,
-		RetryAttempts:    5,
-		MaxIdleConns:     2,
-		MaxOpenConns:     5,
-		ConnMaxLifetime:  1 * time.Hour,
-		LogLevel:         "info",
-		UseOpenTelemetry: false,
-		PingTimeout:      5 * time.Second,
-	}
-)
+// NewMySQLConnector connects to the MySQL database and starts the health check, see NewSQLConnector.
+// The connector DB is replaced when the broken connection is reconnected and Close closes it.
+func NewMySQLConnector(ctx context.Context, databaseDSN string, opt *MySQLConnectionOptions) (*SQLConnector, error) {
+	return NewSQLConnector(ctx, databaseDSN, mysqlDialect, opt)
+}
 
 // InitializeMySQLConn :nodoc:
+//
+// The returned connection is not replaced, so the broken connection is only reconnected with ReconnectCallback,
+// without it the broken connection is only logged. The healthCheckStopFunc doesn't close the connection,
+// use NewMySQLConnector to close it.
 func InitializeMySQLConn(databaseDSN string, opt *MySQLConnectionOptions) (conn *gorm.DB, healthCheckStopFunc func(), err error) {
-	options := applyMySQLConnectionOptions(opt)
-
-	conn, err = openMySQLConn(databaseDSN, options)
-	if err != nil {
-		log.WithField("databaseDSN", databaseDSN).Fatal("failed to connect to MySQL database: ", err)
-	}
-
-	stopTickerCh := make(chan bool)
-	healthCheckStopFunc = func() {
-		stopTickerCh <- true
-	}
-
-	go checkMySQLConnection(conn, databaseDSN, stopTickerCh, options, time.NewTicker(options.PingInterval))
-
-	conn.Logger = NewGormCustomLogger()
-
-	switch options.LogLevel {
-	case "error":
-		conn.Logger = conn.Logger.LogMode(gormLogger.Error)
-	case "warn":
-		conn.Logger = conn.Logger.LogMode(gormLogger.Warn)
-	default:
-		conn.Logger = conn.Logger.LogMode(gormLogger.Info)
-
-	}
-
-	log.Info("Connection to MySQL Server success...")
-	return
-}
-
-func openMySQLConn(dsn string, opts *MySQLConnectionOptions) (*gorm.DB, error) {
-	conn, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
-	if err != nil {
-		log.Error(err)
-		return nil, err
+	options := applySQLConnectionOptions(opt)
+	if err = options.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid MySQL connection options: %w", err)
 	}
 
-	if opts.UseOpenTelemetry {
-		if err := conn.Use(otelgorm.NewPlugin()); err != nil {
-			log.Error(err)
-			return nil, err
+	var onReconnectFailed func(err error)
+	if options.ReconnectCallback != nil {
+		onReconnectFailed = func(err error) {
+			log.Fatal(err)
 		}
 	}
 
-	db, err := conn.DB()
+	connector, err := newSQLConnector(context.Background(), databaseDSN, mysqlDialect, options, onReconnectFailed)
 	if err != nil {
-		log.Error(err)
-		return nil, err
+		log.WithField("databaseDSN", databaseDSN).Fatal("failed to connect to MySQL database: ", err)
 	}
 
-	db.SetMaxIdleConns(opts.MaxIdleConns)
-	db.SetConnMaxLifetime(opts.ConnMaxLifetime)
-	db.SetMaxOpenConns(opts.MaxOpenConns)
-
-	return conn, nil
-}
-
-//gocognit:ignore
-func checkMySQLConnection(db *gorm.DB, databaseDSN string, stopTickerCh chan bool, options *MySQLConnectionOptions, ticker *time.Ticker) {
-
-	for {
-		select {
-		case <-stopTickerCh:
-			ticker.Stop()
-			return
-		case <-ticker.C:
-			db, err := db.DB()
-			if err != nil {
-				log.Error("MySQL got disconnected!")
-				if options.ReconnectCallback == nil {
-					continue
-				}
-				reconnectMySQLConn(databaseDSN, options)
-				continue
-			}
-
-			ctx, cancelFunc := context.WithTimeout(context.TODO(), options.PingTimeout)
-			if err = db.PingContext(ctx); err != nil {
-				log.Error("MySQL got disconnected!")
-				if options.ReconnectCallback == nil {
-					cancelFunc() // fix govet possible context leak
-					continue
-				}
-				reconnectMySQLConn(databaseDSN, options)
-			}
-			cancelFunc()
-		}
-	}
+	return connector.DB(), connector.Stop, nil
 }
 
-func reconnectMySQLConn(databaseDSN string, options *MySQLConnectionOptions) {
-	b := backoff.Backoff{
-		Factor: 2,
-		Jitter: true,
-		Min:    100 * time.Millisecond,
-		Max:    1 * time.Second,
+// ConnectMySQL is the non-fatal InitializeMySQLConn, the errors are returned or reported instead of exiting.
+// The initial connection is retried with backoff up to RetryAttempts times or until the ctx is done,
+// so use context.WithTimeout to set the deadline.
+// The broken connection is reconnected and provided to ReconnectCallback, when the reconnection is exhausted
+// the error is logged and the health check keeps trying on the next ping.
+// Without ReconnectCallback the broken connection is only logged, like InitializeMySQLConn.
+// The healthCheckStopFunc doesn't close the connection, use NewMySQLConnector to close it.
+func ConnectMySQL(ctx context.Context, databaseDSN string, opt *MySQLConnectionOptions) (conn *gorm.DB, healthCheckStopFunc func(), err error) {
+	options := applySQLConnectionOptions(opt)
+	if err = options.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid MySQL connection options: %w", err)
 	}
 
-	for b.Attempt() < float64(options.RetryAttempts) {
-		conn, err := openMySQLConn(databaseDSN, options)
-		if err != nil {
-			log.WithField("databaseDSN", databaseDSN).Error("failed to connect to MySQL database: ", err)
-		}
-
-		if conn != nil {
-			options.ReconnectCallback(conn)
-			break
+	var onReconnectFailed func(err error)
+	if options.ReconnectCallback != nil {
+		onReconnectFailed = func(err error) {
+			log.Error(err)
 		}
-		time.Sleep(b.Duration())
 	}
 
-	if b.Attempt() >= float64(options.RetryAttempts) {
-		log.Fatal("maximum retry to connect database")
-	}
-	b.Reset()
-}
-
-func applyMySQLConnectionOptions(opt *MySQLConnectionOptions) *MySQLConnectionOptions {
-	if opt == nil {
-		return defaultMySQLConnectionOptions
+	connector, err := newSQLConnector(ctx, databaseDSN, mysqlDialect, options, onReconnectFailed)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to MySQL database: %w", err)
 	}
 
-	// if error occurs, also return options from input
-	_ = mergo.Merge(opt, *defaultMySQLConnectionOptions)
-	return opt
+	return connector.DB(), connector.Stop, nil
 }
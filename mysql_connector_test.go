@@ -0,0 +1,51 @@
+package connect
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unreachableMySQLDSN refuses the connection immediately
+const unreachableMySQLDSN = "user:secret@tcp(127.0.0.1:1)/db?timeout=100ms"
+
+func TestNewMySQLConnector(t *testing.T) {
+	t.Run("return the connection error", func(t *testing.T) {
+		connector, err := NewMySQLConnector(context.Background(), unreachableMySQLDSN, &MySQLConnectionOptions{RetryAttempts: 1})
+		assert.ErrorContains(t, err, "failed to connect to mysql database: maximum retry to connect database")
+		assert.Nil(t, connector)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		_, err := NewMySQLConnector(context.Background(), unreachableMySQLDSN, &MySQLConnectionOptions{LogLevel: "debug"})
+		assert.ErrorContains(t, err, "invalid mysql connection options")
+	})
+}
+
+func TestConnectMySQL(t *testing.T) {
+	t.Run("return the error after the retries", func(t *testing.T) {
+		conn, stop, err := ConnectMySQL(context.Background(), unreachableMySQLDSN, &MySQLConnectionOptions{RetryAttempts: 2})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "maximum retry to connect database")
+		assert.Nil(t, conn)
+		assert.Nil(t, stop)
+	})
+
+	t.Run("stop retrying when the deadline is exceeded", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		begin := time.Now()
+		_, _, err := ConnectMySQL(ctx, unreachableMySQLDSN, &MySQLConnectionOptions{RetryAttempts: 100})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(begin), 2*time.Second)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		_, _, err := ConnectMySQL(context.Background(), unreachableMySQLDSN, &MySQLConnectionOptions{LogLevel: "debug"})
+		assert.ErrorContains(t, err, "invalid MySQL connection options")
+	})
+}
@@ -0,0 +1,382 @@
+package connect
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// optionsPkgPath the package of the nested options, the structs of other packages, e.g. *tls.Config, are not loaded
+var optionsPkgPath = reflect.TypeOf(CircuitSetting{}).PkgPath()
+
+// optionsDefaults applies the defaults of the options before their validation, like their constructors do
+var optionsDefaults = map[reflect.Type]func(opt interface{}){
+	reflect.TypeOf(ElasticsearchConnectionOptions{}): func(opt interface{}) {
+		applyElasticsearchConnectionOptions(opt.(*ElasticsearchConnectionOptions))
+	},
+	reflect.TypeOf(GRPCUnaryInterceptorOptions{}): func(opt interface{}) {
+		applyGRPCUnaryInterceptorOptions(opt.(*GRPCUnaryInterceptorOptions))
+	},
+	reflect.TypeOf(HTTPConnectionOptions{}): func(opt interface{}) {
+		applyHTTPConnectionOptions(opt.(*HTTPConnectionOptions))
+	},
+	reflect.TypeOf(RedisConnectionPoolOptions{}): func(opt interface{}) {
+		applyRedisConnectionPoolOptions(opt.(*RedisConnectionPoolOptions))
+	},
+	reflect.TypeOf(SlidingWindowCircuitBreakerOptions{}): func(opt interface{}) {
+		applySlidingWindowCircuitBreakerOptions(opt.(*SlidingWindowCircuitBreakerOptions))
+	},
+	reflect.TypeOf(SQLConnectionOptions{}): func(opt interface{}) {
+		applySQLConnectionOptions(opt.(*SQLConnectionOptions))
+	},
+	reflect.TypeOf(TelemetryOptions{}): func(opt interface{}) {
+		applyTelemetryOptions(opt.(*TelemetryOptions))
+	},
+}
+
+// LoadOptionsFromEnv fills the options struct pointed by opt, e.g. *RedisConnectionPoolOptions, from the environment variables.
+// The variable name is the prefix followed by the field name in upper snake case, e.g. REDIS_POOL_SIZE for PoolSize with REDIS prefix.
+// Nested options use the field name as an additional prefix, e.g. HTTP_CIRCUIT_BREAKER_CONFIG_TIMEOUT,
+// only the options of this package are nested, e.g. TLSConfig *tls.Config is skipped.
+//
+// Durations use time.ParseDuration format, e.g. MYSQL_PING_INTERVAL=2s, and slices are comma separated.
+// Fields without a variable keep their value and fields of unsupported types, e.g. funcs, are skipped.
+// The returned error joins the errors of every invalid variable, then the errors of the options Validate
+// with the defaults applied, the defaults are not set to opt.
+func LoadOptionsFromEnv(prefix string, opt interface{}) error {
+	v, err := optionsValue(opt)
+	if err != nil {
+		return err
+	}
+
+	prefix = strings.TrimSuffix(strings.ToUpper(prefix), "_")
+	if prefix != "" {
+		prefix += "_"
+	}
+
+	if errs := loadStructFromEnv(prefix, v); len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return validateOptions(v)
+}
+
+// LoadOptionsFromMap fills the options struct pointed by opt from a map, e.g. decoded from a YAML or JSON config.
+// The keys match the field names case insensitively, ignoring underscores and dashes, so PoolSize, poolSize and pool_size are equal.
+// Nested options are nested maps, only the options of this package are nested.
+//
+// Durations use time.ParseDuration format, e.g. ping_interval: 2s.
+// The returned error joins the errors of every invalid or unknown key, then the errors of the options Validate
+// with the defaults applied, the defaults are not set to opt.
+func LoadOptionsFromMap(m map[string]interface{}, opt interface{}) error {
+	v, err := optionsValue(opt)
+	if err != nil {
+		return err
+	}
+
+	if errs := loadStructFromMap("", m, v); len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return validateOptions(v)
+}
+
+func optionsValue(opt interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(opt)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("options must be a non-nil pointer to a struct, got %T", opt)
+	}
+	return v.Elem(), nil
+}
+
+func loadStructFromEnv(prefix string, v reflect.Value) (errs []error) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := prefix + toUpperSnakeCase(field.Name)
+		fv := v.Field(i)
+
+		if isNestedOptions(field.Type) {
+			if !hasEnvWithPrefix(name + "_") {
+				continue
+			}
+			if fv.IsNil() {
+				fv.Set(reflect.New(field.Type.Elem()))
+			}
+			errs = append(errs, loadStructFromEnv(name+"_", fv.Elem())...)
+			continue
+		}
+
+		raw, ok := os.LookupEnv(name)
+		if !ok || !isSupportedType(field.Type) {
+			continue
+		}
+
+		if err := setFromString(fv, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+
+	return errs
+}
+
+func loadStructFromMap(path string, m map[string]interface{}, v reflect.Value) (errs []error) {
+	fields := map[string]int{}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			fields[normalizeOptionKey(t.Field(i).Name)] = i
+		}
+	}
+
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys) // report the errors in a stable order
+
+	for _, key := range keys {
+		raw := m[key]
+		name := path + key
+		i, ok := fields[normalizeOptionKey(key)]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown option", name))
+			continue
+		}
+
+		field := t.Field(i)
+		fv := v.Field(i)
+
+		if isNestedOptions(field.Type) {
+			nested, ok := toStringMap(raw)
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s: must be a map, got %T", name, raw))
+				continue
+			}
+			if fv.IsNil() {
+				fv.Set(reflect.New(field.Type.Elem()))
+			}
+			errs = append(errs, loadStructFromMap(name+".", nested, fv.Elem())...)
+			continue
+		}
+
+		if !isSupportedType(field.Type) {
+			errs = append(errs, fmt.Errorf("%s: unsupported option type %s", name, field.Type))
+			continue
+		}
+
+		if err := setFromValue(fv, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+
+	return errs
+}
+
+// isNestedOptions check if the type is a pointer to an options struct of this package, e.g. *CircuitSetting
+func isNestedOptions(t reflect.Type) bool {
+	return t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct && t.Elem().PkgPath() == optionsPkgPath
+}
+
+// validateOptions validates a copy of the loaded options with the defaults applied
+func validateOptions(v reflect.Value) error {
+	opt := copyOptions(v)
+	if apply, ok := optionsDefaults[v.Type()]; ok {
+		apply(opt.Interface())
+	}
+
+	validator, ok := opt.Interface().(interface{ Validate() error })
+	if !ok {
+		return nil
+	}
+	return validator.Validate()
+}
+
+// copyOptions returns a pointer to a copy of the options struct, the nested options are copied too
+// so applying the defaults doesn't change them
+func copyOptions(v reflect.Value) reflect.Value {
+	copied := reflect.New(v.Type())
+	copied.Elem().Set(v)
+	for i := 0; i < v.NumField(); i++ {
+		field := copied.Elem().Field(i)
+		if v.Type().Field(i).IsExported() && isNestedOptions(field.Type()) && !field.IsNil() {
+			field.Set(copyOptions(field.Elem()))
+		}
+	}
+	return copied
+}
+
+func isSupportedType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice:
+		return isSupportedType(t.Elem())
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// setFromString sets v from the string representation, slices are comma separated
+func setFromString(v reflect.Value, raw string) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := setFromString(elem.Elem(), raw); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	case reflect.Slice:
+		var parts []string
+		if raw = strings.TrimSpace(raw); raw != "" {
+			parts = strings.Split(raw, ",")
+		}
+		slice := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, part := range parts {
+			if err := setFromString(slice.Index(i), strings.TrimSpace(part)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid bool %q", raw)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer %q", raw)
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported option type %s", v.Type())
+	}
+
+	return nil
+}
+
+// setFromValue sets v from a decoded YAML or JSON value
+func setFromValue(v reflect.Value, raw interface{}) error {
+	switch value := raw.(type) {
+	case string:
+		return setFromString(v, value)
+	case []interface{}:
+		if v.Kind() != reflect.Slice {
+			return fmt.Errorf("must not be a list")
+		}
+		slice := reflect.MakeSlice(v.Type(), len(value), len(value))
+		for i, item := range value {
+			if err := setFromValue(slice.Index(i), item); err != nil {
+				return fmt.Errorf("[%d]: %w", i, err)
+			}
+		}
+		v.Set(slice)
+		return nil
+	case bool, int, int64, uint64, float64:
+		if v.Type() == durationType {
+			return fmt.Errorf("invalid duration %v, must be a string, e.g. 2s", value)
+		}
+		if v.Kind() == reflect.Slice {
+			return fmt.Errorf("must be a list")
+		}
+		if f, ok := value.(float64); ok && f == float64(int64(f)) {
+			value = int64(f) // JSON numbers are decoded as float64
+		}
+		return setFromString(v, fmt.Sprint(value))
+	default:
+		return fmt.Errorf("unsupported value type %T", raw)
+	}
+}
+
+// toStringMap converts a nested map decoded by either YAML or JSON
+func toStringMap(raw interface{}) (map[string]interface{}, bool) {
+	switch m := raw.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(m))
+		for key, value := range m {
+			converted[fmt.Sprint(key)] = value
+		}
+		return converted, true
+	default:
+		return nil, false
+	}
+}
+
+func normalizeOptionKey(key string) string {
+	key = strings.ReplaceAll(key, "_", "")
+	key = strings.ReplaceAll(key, "-", "")
+	return strings.ToLower(key)
+}
+
+func hasEnvWithPrefix(prefix string) bool {
+	for _, env := range os.Environ() {
+		if strings.HasPrefix(env, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// toUpperSnakeCase converts the field name, e.g. TLSHandshakeTimeout to TLS_HANDSHAKE_TIMEOUT
+func toUpperSnakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prevLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			// keep the plural acronym together, e.g. ExcludedIPs to EXCLUDED_IPS
+			pluralAcronym := nextLower && runes[i+1] == 's' && (i+2 == len(runes) || unicode.IsUpper(runes[i+2]))
+			if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower && !pluralAcronym) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
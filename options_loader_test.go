@@ -0,0 +1,148 @@
+package connect
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadOptionsFromEnv(t *testing.T) {
+	t.Run("fill the options", func(t *testing.T) {
+		t.Setenv("HTTP_TLS_HANDSHAKE_TIMEOUT", "2s")
+		t.Setenv("HTTP_USE_OPEN_TELEMETRY", "true")
+		t.Setenv("HTTP_NAME", "payment")
+		t.Setenv("HTTP_CIRCUIT_BREAKER_CONFIG_TIMEOUT", "1000")
+
+		opt := &HTTPConnectionOptions{Timeout: time.Second}
+		require.NoError(t, LoadOptionsFromEnv("HTTP", opt))
+
+		assert.Equal(t, 2*time.Second, opt.TLSHandshakeTimeout)
+		assert.True(t, opt.UseOpenTelemetry)
+		assert.Equal(t, "payment", opt.Name)
+		assert.Equal(t, time.Second, opt.Timeout)
+		require.NotNil(t, opt.CircuitBreakerConfig)
+		assert.Equal(t, 1000, opt.CircuitBreakerConfig.Timeout)
+	})
+
+	t.Run("slices and nested options", func(t *testing.T) {
+		t.Setenv("GRPC_RATE_LIMITER_LIMIT", "50")
+		t.Setenv("GRPC_RATE_LIMITER_EXCLUDED_IPS", "10.0.0.1, 10.0.0.2")
+
+		opt := &GRPCUnaryInterceptorOptions{}
+		require.NoError(t, LoadOptionsFromEnv("grpc_", opt))
+
+		require.NotNil(t, opt.RateLimiter)
+		assert.EqualValues(t, 50, opt.RateLimiter.Limit)
+		assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, opt.RateLimiter.ExcludedIPs)
+		assert.Zero(t, opt.RateLimiter.Period, "the defaults are only applied to validate")
+		assert.Nil(t, opt.RetryPolicy)
+	})
+
+	t.Run("skip the structs of other packages", func(t *testing.T) {
+		t.Setenv("REDIS_TLS_CONFIG_INSECURE_SKIP_VERIFY", "true")
+
+		opt := &RedisConnectionPoolOptions{}
+		require.NoError(t, LoadOptionsFromEnv("REDIS", opt))
+
+		assert.Nil(t, opt.TLSConfig)
+	})
+
+	t.Run("validate the options", func(t *testing.T) {
+		t.Setenv("MYSQL_MAX_IDLE_CONNS", "20")
+		t.Setenv("MYSQL_MAX_OPEN_CONNS", "10")
+
+		opt := &MySQLConnectionOptions{}
+		err := LoadOptionsFromEnv("MYSQL", opt)
+		assert.EqualError(t, err, "MaxIdleConns: must not be greater than MaxOpenConns")
+		assert.Zero(t, opt.PingInterval)
+	})
+
+	t.Run("report every bad field", func(t *testing.T) {
+		t.Setenv("REDIS_POOL_SIZE", "ten")
+		t.Setenv("REDIS_IDLE_TIMEOUT", "10")
+		t.Setenv("REDIS_READ_ONLY", "yes")
+
+		err := LoadOptionsFromEnv("REDIS", &RedisConnectionPoolOptions{})
+		require.Error(t, err)
+		assert.ErrorContains(t, err, `REDIS_POOL_SIZE: invalid integer "ten"`)
+		assert.ErrorContains(t, err, `REDIS_IDLE_TIMEOUT: invalid duration "10"`)
+		assert.ErrorContains(t, err, `REDIS_READ_ONLY: invalid bool "yes"`)
+	})
+
+	t.Run("not a struct pointer", func(t *testing.T) {
+		assert.Error(t, LoadOptionsFromEnv("REDIS", RedisConnectionPoolOptions{}))
+	})
+}
+
+func TestLoadOptionsFromMap(t *testing.T) {
+	t.Run("fill the options from JSON", func(t *testing.T) {
+		var m map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(`{
+			"ping_interval": "2s",
+			"maxOpenConns": 10,
+			"LogLevel": "warn",
+			"use-open-telemetry": true
+		}`), &m))
+
+		opt := &MySQLConnectionOptions{}
+		require.NoError(t, LoadOptionsFromMap(m, opt))
+
+		assert.Equal(t, 2*time.Second, opt.PingInterval)
+		assert.Equal(t, 10, opt.MaxOpenConns)
+		assert.Equal(t, "warn", opt.LogLevel)
+		assert.True(t, opt.UseOpenTelemetry)
+	})
+
+	t.Run("nested maps", func(t *testing.T) {
+		opt := &GRPCUnaryInterceptorOptions{}
+		require.NoError(t, LoadOptionsFromMap(map[string]interface{}{
+			"retry_policy": map[interface{}]interface{}{
+				"backoff_multiplier": 1.5,
+				"retryable_codes":    []interface{}{14, 4},
+			},
+		}, opt))
+
+		require.NotNil(t, opt.RetryPolicy)
+		assert.Equal(t, 1.5, opt.RetryPolicy.BackoffMultiplier)
+		assert.Len(t, opt.RetryPolicy.RetryableCodes, 2)
+	})
+
+	t.Run("skip the structs of other packages", func(t *testing.T) {
+		err := LoadOptionsFromMap(map[string]interface{}{
+			"tls_config": map[string]interface{}{"insecure_skip_verify": true},
+		}, &RedisConnectionPoolOptions{})
+		assert.EqualError(t, err, "tls_config: unsupported option type *tls.Config")
+	})
+
+	t.Run("validate the nested options", func(t *testing.T) {
+		err := LoadOptionsFromMap(map[string]interface{}{
+			"retry_policy": map[string]interface{}{"max_backoff": "-1s"},
+		}, &GRPCUnaryInterceptorOptions{})
+		require.Error(t, err)
+		assert.EqualError(t, err, "RetryPolicy.MaxBackoff: must not be negative")
+	})
+
+	t.Run("report every bad field", func(t *testing.T) {
+		err := LoadOptionsFromMap(map[string]interface{}{
+			"ping_interval":      2,
+			"max_idle_conns":     "two",
+			"unknown":            true,
+			"reconnect_callback": "func",
+		}, &CockroachDBConnectionOptions{})
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "ping_interval: invalid duration 2, must be a string, e.g. 2s")
+		assert.ErrorContains(t, err, `max_idle_conns: invalid integer "two"`)
+		assert.ErrorContains(t, err, "unknown: unknown option")
+		assert.ErrorContains(t, err, "reconnect_callback: unsupported option type func(*gorm.DB)")
+	})
+}
+
+func TestToUpperSnakeCase(t *testing.T) {
+	assert.Equal(t, "TLS_HANDSHAKE_TIMEOUT", toUpperSnakeCase("TLSHandshakeTimeout"))
+	assert.Equal(t, "USE_OPEN_TELEMETRY", toUpperSnakeCase("UseOpenTelemetry"))
+	assert.Equal(t, "EXCLUDED_IPS", toUpperSnakeCase("ExcludedIPs"))
+	assert.Equal(t, "MAX_CONNS_PER_HOST", toUpperSnakeCase("MaxConnsPerHost"))
+}
@@ -2,7 +2,13 @@ package connect
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	redigo "github.com/gomodule/redigo/redis"
@@ -14,7 +20,7 @@ import (
 // RedisConnectionPoolOptions options for the redis connection
 type RedisConnectionPoolOptions struct {
 	// Dial timeout for establishing new connections.
-	// Default is 5 seconds. Only for go-redis.
+	// Default is 5 seconds.
 	DialTimeout time.Duration
 
 	// Enables read-only commands on slave nodes.
@@ -22,12 +28,12 @@ type RedisConnectionPoolOptions struct {
 
 	// Timeout for socket reads. If reached, commands will fail
 	// with a timeout instead of blocking. Use value -1 for no timeout and 0 for default.
-	// Default is 3 seconds. Only for go-redis.
+	// Default is 2 seconds.
 	ReadTimeout time.Duration
 
 	// Timeout for socket writes. If reached, commands will fail
-	// with a timeout instead of blocking.
-	// Default is ReadTimeout. Only for go-redis.
+	// with a timeout instead of blocking. Use value -1 for no timeout and 0 for default.
+	// Default is 2 seconds.
 	WriteTimeout time.Duration
 
 	// Number of idle connections in the pool.
@@ -61,8 +67,22 @@ type RedisConnectionPoolOptions struct {
 	// default on gor-edis: 32KiB (32768 bytes)
 	// default on go-connect: 4096 bytes
 	WriteBufferSize int
+
+	// TLSConfig the TLS config of the rediss URLs, e.g. the custom CA, the client certificates or the SNI.
+	// The server name defaults to the host of the URL.
+	// The cluster and sentinel connections use TLS when it's set.
+	TLSConfig *tls.Config
+
+	// UseMetrics flag if the pool will record the pool stats metrics.
+	// On go-redis the command durations are also recorded.
+	UseMetrics bool
 }
 
+const (
+	redisClusterScheme  = "redis+cluster"
+	redisSentinelScheme = "redis+sentinel"
+)
+
 var defaultRedisConnectionPoolOptions = &RedisConnectionPoolOptions{
 	IdleCount:       20,
 	PoolSize:        100,
@@ -82,27 +102,142 @@ func NewRedigoRedisConnectionPool(url string, opt *RedisConnectionPoolOptions) (
 	}
 
 	options := applyRedisConnectionPoolOptions(opt)
+	if err := options.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid redis connection pool options: %w", err)
+	}
 
-	return &redigo.Pool{
-		MaxIdle:     options.IdleCount,
-		MaxActive:   options.PoolSize,
-		IdleTimeout: options.IdleTimeout,
-		Dial: func() (redigo.Conn, error) {
-			c, err := redigo.DialURL(url)
-			if err != nil {
-				return nil, err
-			}
-			return c, err
-		},
+	dial, err := newRedigoDialFunc(url, options)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := &redigo.Pool{
+		MaxIdle:         options.IdleCount,
+		MaxActive:       options.PoolSize,
+		IdleTimeout:     options.IdleTimeout,
+		Dial:            dial,
 		MaxConnLifetime: options.MaxConnLifetime,
 		TestOnBorrow: func(c redigo.Conn, _ time.Time) error {
 			_, err := c.Do("PING")
 			return err
 		},
 		Wait: true, // wait for connection available when maxActive is reached
+	}
+
+	if options.UseMetrics {
+		if err := registerRedigoPoolMetrics(pool, redisPoolName(url)); err != nil {
+			return nil, err
+		}
+	}
+
+	return pool, nil
+}
+
+// redigoURL the parsed redis URL of the redigo pool
+type redigoURL struct {
+	host     string
+	port     string
+	username string
+	password string
+	db       int
+	useTLS   bool
+}
+
+func (u *redigoURL) address() string {
+	return net.JoinHostPort(u.host, u.port)
+}
+
+// parseRedigoURL parses the redis URL, the host defaults to localhost and the port to 6379
+func parseRedigoURL(rawURL string) (*redigoURL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := &redigoURL{useTLS: u.Scheme == "rediss"}
+	parsed.host, parsed.port, err = net.SplitHostPort(u.Host)
+	if err != nil {
+		parsed.host, parsed.port = u.Host, "6379" // the port is missing
+	}
+	if parsed.host == "" {
+		parsed.host = "localhost"
+	}
+
+	if path := strings.TrimPrefix(u.Path, "/"); path != "" {
+		if parsed.db, err = strconv.Atoi(path); err != nil || parsed.db < 0 {
+			return nil, fmt.Errorf("invalid redis database: %s", path)
+		}
+	}
+
+	if u.User != nil {
+		parsed.username = u.User.Username()
+		parsed.password, _ = u.User.Password()
+	}
+	return parsed, nil
+}
+
+// newRedigoDialFunc returns the dial func of the redis URL with the timeouts and the TLS config of the options.
+// Unlike redigo.DialURL, the username of the URL is authenticated with the ACL, i.e. AUTH username password.
+func newRedigoDialFunc(rawURL string, options *RedisConnectionPoolOptions) (func() (redigo.Conn, error), error) {
+	u, err := parseRedigoURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	dialOptions := []redigo.DialOption{
+		redigo.DialConnectTimeout(options.DialTimeout),
+		redigo.DialReadTimeout(max(options.ReadTimeout, 0)), // redigo has no timeout on zero
+		redigo.DialWriteTimeout(max(options.WriteTimeout, 0)),
+		redigo.DialUseTLS(u.useTLS),
+	}
+	if options.TLSConfig != nil {
+		dialOptions = append(dialOptions, redigo.DialTLSConfig(withRedisServerName(options.TLSConfig, u.host)))
+	}
+
+	return func() (redigo.Conn, error) {
+		c, err := redigo.Dial("tcp", u.address(), dialOptions...)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := initRedigoConn(c, u.username, u.password, u.db); err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+		return c, nil
 	}, nil
 }
 
+// initRedigoConn authenticates and selects the database of the new connection
+func initRedigoConn(c redigo.Conn, username, password string, db int) error {
+	switch {
+	case username != "":
+		if _, err := c.Do("AUTH", username, password); err != nil {
+			return err
+		}
+	case password != "":
+		if _, err := c.Do("AUTH", password); err != nil {
+			return err
+		}
+	}
+
+	if db != 0 {
+		if _, err := c.Do("SELECT", db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withRedisServerName returns a copy of the TLS config, the server name defaults to the host
+func withRedisServerName(tlsConfig *tls.Config, host string) *tls.Config {
+	tlsConfig = tlsConfig.Clone()
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = host
+	}
+	return tlsConfig
+}
+
 // NewGoRedisConnectionPool uses goredis library to establish the redis connection pool
 func NewGoRedisConnectionPool(url string, opt *RedisConnectionPoolOptions) (*goredis.Client, error) {
 	options, err := goredis.ParseURL(url)
@@ -111,6 +246,10 @@ func NewGoRedisConnectionPool(url string, opt *RedisConnectionPoolOptions) (*gor
 	}
 
 	myOptions := applyRedisConnectionPoolOptions(opt)
+	if err := myOptions.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid redis connection pool options: %w", err)
+	}
+
 	options.MinIdleConns = myOptions.IdleCount
 	options.PoolSize = myOptions.PoolSize
 	options.ConnMaxIdleTime = myOptions.IdleTimeout
@@ -120,6 +259,9 @@ func NewGoRedisConnectionPool(url string, opt *RedisConnectionPoolOptions) (*gor
 	options.ReadTimeout = myOptions.ReadTimeout
 	options.ReadBufferSize = myOptions.ReadBufferSize
 	options.WriteBufferSize = myOptions.WriteBufferSize
+	if options.TLSConfig != nil && myOptions.TLSConfig != nil {
+		options.TLSConfig = withRedisServerName(myOptions.TLSConfig, options.TLSConfig.ServerName)
+	}
 
 	client := goredis.NewClient(options)
 
@@ -128,43 +270,259 @@ func NewGoRedisConnectionPool(url string, opt *RedisConnectionPoolOptions) (*gor
 		return nil, err
 	}
 
+	if myOptions.UseMetrics {
+		if err := redisotel.InstrumentMetrics(client); err != nil {
+			return nil, err
+		}
+	}
+
 	return client, nil
 }
 
 // NewGoRedisClusterConnectionPool uses goredis library to establish the redis cluster connection pool
 func NewGoRedisClusterConnectionPool(urls []string, opt *RedisConnectionPoolOptions) (*goredis.ClusterClient, error) {
 	for _, url := range urls {
-		if isValidRedisStandaloneURL(url) {
-			return nil, errors.New("invalid redis-cluster URL: " + url)
+		if !isValidRedisAddr(url) {
+			return nil, errors.New("invalid redis-cluster URL, must be host:port: " + url)
 		}
 	}
+
+	return newGoRedisClusterConnectionPool(&goredis.ClusterOptions{Addrs: urls}, opt)
+}
+
+// newGoRedisClusterConnectionPool applies the pool options to the cluster options, e.g. parsed from a cluster URL
+func newGoRedisClusterConnectionPool(clusterOptions *goredis.ClusterOptions, opt *RedisConnectionPoolOptions) (*goredis.ClusterClient, error) {
 	options := applyRedisConnectionPoolOptions(opt)
+	if err := options.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid redis connection pool options: %w", err)
+	}
 
-	client := goredis.NewClusterClient(&goredis.ClusterOptions{
-		Addrs:           urls,
-		ConnMaxIdleTime: options.IdleTimeout,
-		MinIdleConns:    options.IdleCount,
-		ConnMaxLifetime: options.MaxConnLifetime,
-		PoolSize:        options.PoolSize,
-		DialTimeout:     options.DialTimeout,
-		WriteTimeout:    options.WriteTimeout,
-		ReadTimeout:     options.ReadTimeout,
-		ReadOnly:        options.ReadOnly,
-		ReadBufferSize:  options.ReadBufferSize,
-		WriteBufferSize: options.WriteBufferSize,
-		OnConnect: func(ctx context.Context, conn *goredis.Conn) error {
-			return conn.Ping(ctx).Err()
-		},
-	})
+	clusterOptions.ConnMaxIdleTime = options.IdleTimeout
+	clusterOptions.MinIdleConns = options.IdleCount
+	clusterOptions.ConnMaxLifetime = options.MaxConnLifetime
+	clusterOptions.PoolSize = options.PoolSize
+	clusterOptions.DialTimeout = options.DialTimeout
+	clusterOptions.WriteTimeout = options.WriteTimeout
+	clusterOptions.ReadTimeout = options.ReadTimeout
+	clusterOptions.ReadOnly = options.ReadOnly
+	clusterOptions.ReadBufferSize = options.ReadBufferSize
+	clusterOptions.WriteBufferSize = options.WriteBufferSize
+	if options.TLSConfig != nil {
+		clusterOptions.TLSConfig = options.TLSConfig
+	}
+	clusterOptions.OnConnect = func(ctx context.Context, conn *goredis.Conn) error {
+		return conn.Ping(ctx).Err()
+	}
+
+	client := goredis.NewClusterClient(clusterOptions)
 
 	// Enable tracing instrumentation.
 	if err := redisotel.InstrumentTracing(client); err != nil {
 		return nil, err
 	}
 
+	if options.UseMetrics {
+		if err := redisotel.InstrumentMetrics(client); err != nil {
+			return nil, err
+		}
+	}
+
 	return client, nil
 }
 
+// NewGoRedisFailoverConnectionPool uses goredis library to establish the redis connection pool to the master discovered by the sentinels.
+// With ReadOnly, the read-only commands are routed randomly to the master or the replicas through a *goredis.ClusterClient,
+// otherwise every command goes to the master through a *goredis.Client.
+func NewGoRedisFailoverConnectionPool(masterName string, sentinelAddrs []string, opt *RedisConnectionPoolOptions) (goredis.UniversalClient, error) {
+	if masterName == "" {
+		return nil, errors.New("redis master name must not be empty")
+	}
+	if len(sentinelAddrs) == 0 {
+		return nil, errors.New("redis sentinel addresses must not be empty")
+	}
+	for _, addr := range sentinelAddrs {
+		if !isValidRedisAddr(addr) {
+			return nil, errors.New("invalid redis sentinel address, must be host:port: " + addr)
+		}
+	}
+
+	return newGoRedisFailoverConnectionPool(&goredis.FailoverOptions{
+		MasterName:    masterName,
+		SentinelAddrs: sentinelAddrs,
+	}, opt)
+}
+
+// newGoRedisFailoverConnectionPool applies the pool options to the failover options, e.g. parsed from a sentinel URL
+func newGoRedisFailoverConnectionPool(failoverOptions *goredis.FailoverOptions, opt *RedisConnectionPoolOptions) (goredis.UniversalClient, error) {
+	options := applyRedisConnectionPoolOptions(opt)
+	if err := options.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid redis connection pool options: %w", err)
+	}
+
+	failoverOptions.MinIdleConns = options.IdleCount
+	failoverOptions.PoolSize = options.PoolSize
+	failoverOptions.ConnMaxIdleTime = options.IdleTimeout
+	failoverOptions.ConnMaxLifetime = options.MaxConnLifetime
+	failoverOptions.DialTimeout = options.DialTimeout
+	failoverOptions.WriteTimeout = options.WriteTimeout
+	failoverOptions.ReadTimeout = options.ReadTimeout
+	failoverOptions.ReadBufferSize = options.ReadBufferSize
+	failoverOptions.WriteBufferSize = options.WriteBufferSize
+	failoverOptions.RouteRandomly = options.ReadOnly
+	if options.TLSConfig != nil {
+		failoverOptions.TLSConfig = options.TLSConfig
+	}
+
+	var client goredis.UniversalClient
+	if options.ReadOnly {
+		client = goredis.NewFailoverClusterClient(failoverOptions)
+	} else {
+		client = goredis.NewFailoverClient(failoverOptions)
+	}
+
+	// Enable tracing instrumentation.
+	if err := redisotel.InstrumentTracing(client); err != nil {
+		return nil, err
+	}
+
+	if options.UseMetrics {
+		if err := redisotel.InstrumentMetrics(client); err != nil {
+			return nil, err
+		}
+	}
+
+	return client, nil
+}
+
+// NewRedisClient uses goredis library to establish the redis connection pool of the topology picked by the URL scheme,
+// so switching the topology only changes the URL:
+//   - redis://[user:password@]host:port[/db] or rediss:// for TLS, see NewGoRedisConnectionPool
+//   - redis+cluster://[user:password@]host1:port,host2:port, see NewGoRedisClusterConnectionPool
+//   - redis+sentinel://[user:password@]master@host1:port,host2:port[/db], see NewGoRedisFailoverConnectionPool
+//
+// The credentials of the cluster and sentinel URLs authenticate to the redis nodes, not to the sentinels.
+func NewRedisClient(url string, opt *RedisConnectionPoolOptions) (goredis.UniversalClient, error) {
+	scheme, _, _ := strings.Cut(url, "://")
+	switch scheme {
+	case "redis", "rediss":
+		client, err := NewGoRedisConnectionPool(url, opt)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	case redisClusterScheme:
+		u, err := parseRedisMultiHostURL(url, false)
+		if err != nil {
+			return nil, err
+		}
+		if u.db != 0 {
+			return nil, errors.New("invalid redis-cluster URL: the database must be 0")
+		}
+
+		client, err := newGoRedisClusterConnectionPool(&goredis.ClusterOptions{
+			Addrs:    u.addrs,
+			Username: u.username,
+			Password: u.password,
+		}, opt)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	case redisSentinelScheme:
+		u, err := parseRedisMultiHostURL(url, true)
+		if err != nil {
+			return nil, err
+		}
+
+		return newGoRedisFailoverConnectionPool(&goredis.FailoverOptions{
+			MasterName:    u.masterName,
+			SentinelAddrs: u.addrs,
+			Username:      u.username,
+			Password:      u.password,
+			DB:            u.db,
+		}, opt)
+	default:
+		return nil, fmt.Errorf("unsupported redis URL scheme %q, must be redis, rediss, %s or %s", scheme, redisClusterScheme, redisSentinelScheme)
+	}
+}
+
+// redisMultiHostURL the parts of a cluster or sentinel URL
+type redisMultiHostURL struct {
+	username   string
+	password   string
+	masterName string
+	addrs      []string
+	db         int
+}
+
+// parseRedisMultiHostURL parses the cluster or sentinel URL, net/url doesn't support the comma separated hosts.
+// The userinfo and the master name are percent-encoded.
+func parseRedisMultiHostURL(rawURL string, withMasterName bool) (*redisMultiHostURL, error) {
+	scheme, rest, _ := strings.Cut(rawURL, "://")
+	if strings.ContainsAny(rest, "?#") {
+		return nil, fmt.Errorf("invalid %s URL: the query and the fragment are not supported", scheme)
+	}
+
+	u := &redisMultiHostURL{}
+	authority, path, _ := strings.Cut(rest, "/")
+	userinfo, hosts := "", authority
+	if i := strings.LastIndex(authority, "@"); i >= 0 {
+		userinfo, hosts = authority[:i], authority[i+1:]
+	}
+
+	var err error
+	if withMasterName {
+		credentials, masterName := "", userinfo
+		if i := strings.LastIndex(userinfo, "@"); i >= 0 {
+			credentials, masterName = userinfo[:i], userinfo[i+1:]
+		}
+		if u.masterName, err = url.PathUnescape(masterName); err != nil || u.masterName == "" {
+			return nil, fmt.Errorf("invalid %s URL: the master name is required, e.g. %s://mymaster@host:26379", scheme, scheme)
+		}
+		userinfo = credentials
+	}
+
+	username, password, _ := strings.Cut(userinfo, ":")
+	if u.username, err = url.PathUnescape(username); err != nil {
+		return nil, fmt.Errorf("invalid %s URL: invalid username", scheme)
+	}
+	if u.password, err = url.PathUnescape(password); err != nil {
+		return nil, fmt.Errorf("invalid %s URL: invalid password", scheme)
+	}
+
+	for _, addr := range strings.Split(hosts, ",") {
+		addr = strings.TrimSpace(addr)
+		if !isValidRedisAddr(addr) {
+			return nil, fmt.Errorf("invalid %s URL: the host must be host:port, got %q", scheme, addr)
+		}
+		u.addrs = append(u.addrs, addr)
+	}
+
+	if path != "" {
+		if u.db, err = strconv.Atoi(path); err != nil || u.db < 0 {
+			return nil, fmt.Errorf("invalid %s URL: invalid database %q", scheme, path)
+		}
+	}
+
+	return u, nil
+}
+
+// Validate returns the joined errors of every invalid field
+func (o *RedisConnectionPoolOptions) Validate() error {
+	v := &optionsValidator{}
+	v.check(o.DialTimeout >= 0, "DialTimeout", "must not be negative")
+	v.check(o.ReadTimeout >= -2, "ReadTimeout", "must be -1, -2 or not negative")
+	v.check(o.WriteTimeout >= -2, "WriteTimeout", "must be -1, -2 or not negative")
+	v.check(o.IdleCount >= 0, "IdleCount", "must not be negative")
+	v.check(o.PoolSize >= 0, "PoolSize", "must not be negative")
+	v.check(o.PoolSize <= 0 || o.IdleCount <= o.PoolSize, "IdleCount", "must not be greater than PoolSize")
+	v.check(o.IdleTimeout >= 0, "IdleTimeout", "must not be negative")
+	v.check(o.MaxConnLifetime >= 0, "MaxConnLifetime", "must not be negative")
+	v.check(o.ReadBufferSize >= 0, "ReadBufferSize", "must not be negative")
+	v.check(o.WriteBufferSize >= 0, "WriteBufferSize", "must not be negative")
+	return v.err()
+}
+
 func applyRedisConnectionPoolOptions(opt *RedisConnectionPoolOptions) *RedisConnectionPoolOptions {
 	if opt == nil {
 		return defaultRedisConnectionPoolOptions
@@ -174,7 +532,22 @@ func applyRedisConnectionPoolOptions(opt *RedisConnectionPoolOptions) *RedisConn
 	return opt
 }
 
+// redisPoolName returns the host of the redis URL without the credentials
+func redisPoolName(redisURL string) string {
+	u, err := url.Parse(redisURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
 func isValidRedisStandaloneURL(url string) bool {
 	_, err := goredis.ParseURL(url)
 	return err == nil
 }
+
+// isValidRedisAddr check if the address is host:port, not a URL
+func isValidRedisAddr(addr string) bool {
+	_, _, err := net.SplitHostPort(addr)
+	return err == nil && !strings.Contains(addr, "://")
+}
@@ -0,0 +1,280 @@
+package connect
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// redisPipelineSpanName is the span name of the pipelined commands flushed by Do("")
+	redisPipelineSpanName = "pipeline"
+	// maxRedisStatementArgs is the maximum number of arguments written in the db.statement
+	maxRedisStatementArgs = 10
+	// redisPipelineLengthKey is the number of commands of a pipeline
+	redisPipelineLengthKey = attribute.Key("db.redis.pipeline_length")
+)
+
+// TracedRedigoPool redigo pool creating a client span for every command of its connections.
+// The connections implement redigo.ConnWithContext, the span is the child of the span in the ctx of DoContext.
+type TracedRedigoPool struct {
+	*redigo.Pool
+	attrs []attribute.KeyValue
+}
+
+// redigoConnWithContext the methods of redigo.ConnWithContext, which is not declared before redigo v1.8.0,
+// so the module still builds for the modules selecting github.com/gomodule/redigo v2.0.0+incompatible
+type redigoConnWithContext interface {
+	DoContext(ctx context.Context, cmd string, args ...interface{}) (interface{}, error)
+	ReceiveContext(ctx context.Context) (interface{}, error)
+}
+
+var (
+	_ redigoConnWithContext  = (*tracedRedigoConn)(nil)
+	_ redigo.ConnWithTimeout = (*tracedRedigoConn)(nil)
+)
+
+// NewTracedRedigoRedisConnectionPool creates the redigo pool of NewRedigoRedisConnectionPool with the OpenTelemetry tracing
+func NewTracedRedigoRedisConnectionPool(url string, opt *RedisConnectionPoolOptions) (*TracedRedigoPool, error) {
+	pool, err := NewRedigoRedisConnectionPool(url, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := parseRedigoURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemRedis,
+		semconv.NetPeerNameKey.String(u.host),
+		semconv.DBRedisDBIndexKey.Int(u.db),
+	}
+	if port, err := strconv.Atoi(u.port); err == nil {
+		attrs = append(attrs, semconv.NetPeerPortKey.Int(port))
+	}
+
+	return &TracedRedigoPool{Pool: pool, attrs: attrs}, nil
+}
+
+// Get gets a traced connection, the application must close the returned connection
+func (p *TracedRedigoPool) Get() redigo.Conn {
+	return p.wrap(p.Pool.Get())
+}
+
+// GetContext gets a traced connection using the provided context, the application must close the returned connection
+func (p *TracedRedigoPool) GetContext(ctx context.Context) (redigo.Conn, error) {
+	c, err := p.Pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return p.wrap(c), nil
+}
+
+func (p *TracedRedigoPool) wrap(c redigo.Conn) redigo.Conn {
+	return &tracedRedigoConn{
+		Conn:   c,
+		tracer: newConfig().TracerProvider.Tracer(instrumentationName),
+		attrs:  p.attrs,
+	}
+}
+
+// tracedRedigoConn traces the commands of the connection.
+// The pipelined commands are traced when their replies are received, by Receive or Do.
+type tracedRedigoConn struct {
+	redigo.Conn
+	tracer trace.Tracer
+	attrs  []attribute.KeyValue
+
+	mu sync.Mutex
+	// pending the statements of the sent commands waiting for their replies
+	pending []string
+}
+
+// Do sends the command and returns the received reply, the span has no parent
+func (c *tracedRedigoConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	return c.DoContext(context.Background(), cmd, args...)
+}
+
+// DoContext sends the command and returns the received reply, the span is the child of the span in the ctx
+func (c *tracedRedigoConn) DoContext(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	name, statements := c.doStatements(cmd, args)
+	if len(statements) == 0 {
+		return redigoDoContext(ctx, c.Conn, cmd, args...) // nothing to flush
+	}
+	return c.trace(ctx, name, statements, func() (interface{}, error) {
+		return redigoDoContext(ctx, c.Conn, cmd, args...)
+	})
+}
+
+// DoWithTimeout sends the command and returns the received reply with the read timeout
+func (c *tracedRedigoConn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	name, statements := c.doStatements(cmd, args)
+	if len(statements) == 0 {
+		return redigo.DoWithTimeout(c.Conn, timeout, cmd, args...)
+	}
+	return c.trace(context.Background(), name, statements, func() (interface{}, error) {
+		return redigo.DoWithTimeout(c.Conn, timeout, cmd, args...)
+	})
+}
+
+// Send writes the command to the client's output buffer, it's traced when its reply is received
+func (c *tracedRedigoConn) Send(cmd string, args ...interface{}) error {
+	if err := c.Conn.Send(cmd, args...); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.pending = append(c.pending, redisStatement(cmd, args))
+	c.mu.Unlock()
+	return nil
+}
+
+// Receive receives the reply of the first pending command, the span has no parent
+func (c *tracedRedigoConn) Receive() (interface{}, error) {
+	return c.ReceiveContext(context.Background())
+}
+
+// ReceiveContext receives the reply of the first pending command, the span is the child of the span in the ctx
+func (c *tracedRedigoConn) ReceiveContext(ctx context.Context) (interface{}, error) {
+	statement, ok := c.popPending()
+	if !ok {
+		// e.g. the messages of the subscribed channels
+		return redigoReceiveContext(ctx, c.Conn)
+	}
+	return c.trace(ctx, redisCommandName(statement), []string{statement}, func() (interface{}, error) {
+		return redigoReceiveContext(ctx, c.Conn)
+	})
+}
+
+// ReceiveWithTimeout receives the reply of the first pending command with the read timeout
+func (c *tracedRedigoConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	statement, ok := c.popPending()
+	if !ok {
+		return redigo.ReceiveWithTimeout(c.Conn, timeout)
+	}
+	return c.trace(context.Background(), redisCommandName(statement), []string{statement}, func() (interface{}, error) {
+		return redigo.ReceiveWithTimeout(c.Conn, timeout)
+	})
+}
+
+// Close returns the connection to the pool, the replies of the pending commands are discarded
+func (c *tracedRedigoConn) Close() error {
+	c.mu.Lock()
+	c.pending = nil
+	c.mu.Unlock()
+	return c.Conn.Close()
+}
+
+// redigoDoContext is redigo.DoContext, the ctx is ignored when the connection doesn't implement redigo.ConnWithContext
+func redigoDoContext(ctx context.Context, c redigo.Conn, cmd string, args ...interface{}) (interface{}, error) {
+	if cwc, ok := c.(redigoConnWithContext); ok {
+		return cwc.DoContext(ctx, cmd, args...)
+	}
+	return c.Do(cmd, args...)
+}
+
+// redigoReceiveContext is redigo.ReceiveContext, the ctx is ignored when the connection doesn't implement redigo.ConnWithContext
+func redigoReceiveContext(ctx context.Context, c redigo.Conn) (interface{}, error) {
+	if cwc, ok := c.(redigoConnWithContext); ok {
+		return cwc.ReceiveContext(ctx)
+	}
+	return c.Receive()
+}
+
+// doStatements returns the span name and the statements of Do, which flushes and receives the pending commands too.
+// Do("") only flushes the pending commands.
+func (c *tracedRedigoConn) doStatements(cmd string, args []interface{}) (string, []string) {
+	c.mu.Lock()
+	statements := c.pending
+	c.pending = nil
+	c.mu.Unlock()
+
+	if cmd != "" {
+		statements = append(statements, redisStatement(cmd, args))
+	}
+	if len(statements) == 1 {
+		return redisCommandName(statements[0]), statements
+	}
+	return redisPipelineSpanName, statements
+}
+
+func (c *tracedRedigoConn) popPending() (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.pending) == 0 {
+		return "", false
+	}
+	statement := c.pending[0]
+	c.pending = c.pending[1:]
+	return statement, true
+}
+
+// trace runs fn in a client span, the error, including the error reply of redis, is recorded as the error status
+func (c *tracedRedigoConn) trace(ctx context.Context, name string, statements []string, fn func() (interface{}, error)) (interface{}, error) {
+	attrs := append([]attribute.KeyValue{
+		semconv.DBStatementKey.String(strings.Join(statements, "\n")),
+	}, c.attrs...)
+	if name == redisPipelineSpanName {
+		attrs = append(attrs, redisPipelineLengthKey.Int(len(statements)))
+	} else {
+		attrs = append(attrs, semconv.DBOperationKey.String(name))
+	}
+
+	_, span := c.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	defer span.End()
+
+	reply, err := fn()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	return reply, err
+}
+
+// redisStatement returns the sanitized statement of the command, i.e. the command name and the key
+// while the values are replaced with ?, e.g. SET user:1 ?. The arguments of AUTH and HELLO are all replaced.
+func redisStatement(cmd string, args []interface{}) string {
+	cmd = strings.ToUpper(cmd)
+
+	var sb strings.Builder
+	sb.WriteString(cmd)
+	for i, arg := range args {
+		if i == maxRedisStatementArgs {
+			fmt.Fprintf(&sb, " ... (%d more)", len(args)-i)
+			break
+		}
+
+		sb.WriteByte(' ')
+		if i > 0 || cmd == "AUTH" || cmd == "HELLO" {
+			sb.WriteByte('?')
+			continue
+		}
+		if b, ok := arg.([]byte); ok {
+			sb.Write(b)
+			continue
+		}
+		fmt.Fprint(&sb, arg)
+	}
+	return sb.String()
+}
+
+// redisCommandName returns the command name of the statement
+func redisCommandName(statement string) string {
+	name, _, _ := strings.Cut(statement, " ")
+	return name
+}
@@ -0,0 +1,160 @@
+package connect
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, attr := range span.Attributes() {
+		attrs[attr.Key] = attr.Value
+	}
+	return attrs
+}
+
+func TestTracedRedigoPool(t *testing.T) {
+	newPool := func(t *testing.T) (*TracedRedigoPool, *fakeRedisServer) {
+		node := newFakeRedisNode(t, "value")
+		pool, err := NewTracedRedigoRedisConnectionPool("redis://"+node.addr()+"/2", nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = pool.Close() })
+		return pool, node
+	}
+
+	t.Run("trace the command", func(t *testing.T) {
+		recorder := setupSpanRecorder(t)
+		pool, node := newPool(t)
+
+		ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+		conn, err := pool.GetContext(ctx)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = redigoDoContext(ctx, conn, "SET", "user:1", "secret")
+		require.NoError(t, err)
+		parent.End()
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 2)
+		span := spans[0]
+		assert.Equal(t, "SET", span.Name())
+		assert.Equal(t, trace.SpanKindClient, span.SpanKind())
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+
+		host, port, _ := net.SplitHostPort(node.addr())
+		portNumber, _ := strconv.Atoi(port)
+		attrs := spanAttributes(span)
+		assert.Equal(t, "redis", attrs["db.system"].AsString())
+		assert.Equal(t, "SET user:1 ?", attrs["db.statement"].AsString())
+		assert.Equal(t, "SET", attrs["db.operation"].AsString())
+		assert.Equal(t, host, attrs["net.peer.name"].AsString())
+		assert.Equal(t, int64(portNumber), attrs["net.peer.port"].AsInt64())
+		assert.Equal(t, int64(2), attrs["db.redis.database_index"].AsInt64())
+	})
+
+	t.Run("record the error reply", func(t *testing.T) {
+		recorder := setupSpanRecorder(t)
+		pool, _ := newPool(t)
+
+		conn := pool.Get()
+		defer conn.Close()
+		_, err := conn.Do("UNKNOWN")
+		require.Error(t, err)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		assert.Equal(t, otelcodes.Error, spans[0].Status().Code)
+		assert.Contains(t, spans[0].Status().Description, "unknown command")
+	})
+
+	t.Run("trace the replies of the pipeline", func(t *testing.T) {
+		recorder := setupSpanRecorder(t)
+		pool, _ := newPool(t)
+
+		conn := pool.Get()
+		defer conn.Close()
+		require.NoError(t, conn.Send("SET", "key", "value"))
+		require.NoError(t, conn.Send("GET", "key"))
+		require.NoError(t, conn.Flush())
+		assert.Empty(t, recorder.Ended())
+
+		_, err := conn.Receive()
+		require.NoError(t, err)
+		value, err := redigo.String(redigoReceiveContext(context.Background(), conn))
+		require.NoError(t, err)
+		assert.Equal(t, "value", value)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 2)
+		assert.Equal(t, "SET", spans[0].Name())
+		assert.Equal(t, "GET", spans[1].Name())
+		assert.Equal(t, "GET key", spanAttributes(spans[1])["db.statement"].AsString())
+	})
+
+	t.Run("trace the pipeline flushed by Do", func(t *testing.T) {
+		recorder := setupSpanRecorder(t)
+		pool, _ := newPool(t)
+
+		conn := pool.Get()
+		defer conn.Close()
+		require.NoError(t, conn.Send("SET", "key", "value"))
+		require.NoError(t, conn.Send("GET", "key"))
+		replies, err := redigo.Values(conn.Do(""))
+		require.NoError(t, err)
+		assert.Len(t, replies, 2)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		assert.Equal(t, "pipeline", spans[0].Name())
+		attrs := spanAttributes(spans[0])
+		assert.Equal(t, "SET key ?\nGET key", attrs["db.statement"].AsString())
+		assert.Equal(t, int64(2), attrs["db.redis.pipeline_length"].AsInt64())
+
+		_, err = conn.Do("")
+		require.NoError(t, err)
+		assert.Len(t, recorder.Ended(), 1, "nothing to flush")
+	})
+
+	t.Run("invalid URL", func(t *testing.T) {
+		_, err := NewTracedRedigoRedisConnectionPool("127.0.0.1:6379", nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestRedisStatement(t *testing.T) {
+	tests := []struct {
+		name     string
+		cmd      string
+		args     []interface{}
+		expected string
+	}{
+		{name: "no arguments", cmd: "ping", expected: "PING"},
+		{name: "key and values", cmd: "hset", args: []interface{}{[]byte("user:1"), "name", "john"}, expected: "HSET user:1 ? ?"},
+		{name: "numeric key", cmd: "SELECT", args: []interface{}{2}, expected: "SELECT 2"},
+		{name: "auth", cmd: "AUTH", args: []interface{}{"user", "secret"}, expected: "AUTH ? ?"},
+		{
+			name:     "too many arguments",
+			cmd:      "DEL",
+			args:     []interface{}{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"},
+			expected: "DEL a ? ? ? ? ? ? ? ? ? ... (2 more)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, redisStatement(tt.cmd, tt.args))
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package connect
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// maxErrorSampledTraces the maximum number of traces buffered by the errorSpanProcessor,
+	// the spans of the traces started beyond it are dropped
+	maxErrorSampledTraces = 10000
+	// maxErrorSampledTraceSpans the maximum number of spans buffered per trace by the errorSpanProcessor
+	maxErrorSampledTraceSpans = 1000
+)
+
+// SamplingRule sampling ratio for the spans matching the name
+type SamplingRule struct {
+	// SpanName the span name to match, a trailing * matches any suffix.
+	// gRPC spans are named by the full method without the leading slash, e.g. grpc.health.v1.Health/Check or pkg.Service/*
+	SpanName string
+
+	// Ratio the ratio of sampled root spans, 0 never samples
+	Ratio float64
+
+	// OverrideParent flag if the rule also applies to the spans with a parent, e.g. to never sample the health check
+	// even when the caller samples it
+	OverrideParent bool
+}
+
+// matches check if the span name matches the rule
+func (r SamplingRule) matches(spanName string) bool {
+	if prefix, ok := strings.CutSuffix(r.SpanName, "*"); ok {
+		return strings.HasPrefix(spanName, prefix)
+	}
+	return spanName == r.SpanName
+}
+
+// SamplerOptions options for the sampler
+type SamplerOptions struct {
+	// Ratio the ratio of sampled root spans not matching any rule
+	Ratio float64
+
+	// Rules the sampling rules, the first rule matching the span name is used
+	Rules []SamplingRule
+
+	// SampleErrors flag if the spans not sampled are still recorded, instead of dropped,
+	// so the traces with an error span can be exported whole by the span processor of InitTelemetry.
+	// The spans of a dropped local parent and of the rules with OverrideParent stay dropped.
+	SampleErrors bool
+}
+
+type compiledSamplingRule struct {
+	SamplingRule
+	sampler sdktrace.Sampler
+}
+
+type ratioSampler struct {
+	ratio   float64
+	sampler sdktrace.Sampler
+}
+
+// Sampler parent based sampler with per span name rules.
+// The spans with a parent follow the parent decision, so the traces sampled by the caller are kept whole,
+// while the root spans are sampled by the matching rule ratio or the default ratio, which can be updated at runtime.
+type Sampler struct {
+	rules        []compiledSamplingRule
+	ratio        atomic.Pointer[ratioSampler]
+	parentBased  sdktrace.Sampler
+	sampleErrors bool
+}
+
+// NewSampler :nodoc:
+func NewSampler(opt *SamplerOptions) *Sampler {
+	if opt == nil {
+		opt = &SamplerOptions{}
+	}
+
+	s := &Sampler{sampleErrors: opt.SampleErrors}
+	for _, rule := range opt.Rules {
+		s.rules = append(s.rules, compiledSamplingRule{
+			SamplingRule: rule,
+			sampler:      sdktrace.TraceIDRatioBased(rule.Ratio),
+		})
+	}
+	s.SetRatio(opt.Ratio)
+	s.parentBased = sdktrace.ParentBased(rootSampler{s})
+
+	return s
+}
+
+// SetRatio updates the ratio of sampled root spans not matching any rule
+func (s *Sampler) SetRatio(ratio float64) {
+	s.ratio.Store(&ratioSampler{ratio: ratio, sampler: sdktrace.TraceIDRatioBased(ratio)})
+}
+
+// Ratio returns the ratio of sampled root spans not matching any rule
+func (s *Sampler) Ratio() float64 {
+	return s.ratio.Load().ratio
+}
+
+// ShouldSample implements sdktrace.Sampler
+func (s *Sampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if rule := s.match(p.Name); rule != nil && rule.OverrideParent {
+		return rule.sampler.ShouldSample(p)
+	}
+
+	result := s.parentBased.ShouldSample(p)
+	if s.sampleErrors && result.Decision == sdktrace.Drop {
+		// the span of a dropped local parent is never exported, the parent is missing from its trace
+		if parent := trace.SpanContextFromContext(p.ParentContext); !parent.IsValid() || parent.IsRemote() ||
+			trace.SpanFromContext(p.ParentContext).IsRecording() {
+			result.Decision = sdktrace.RecordOnly
+		}
+	}
+	return result
+}
+
+// Description implements sdktrace.Sampler
+func (s *Sampler) Description() string {
+	return fmt.Sprintf("Sampler{ratio:%g,rules:%d}", s.Ratio(), len(s.rules))
+}
+
+func (s *Sampler) match(spanName string) *compiledSamplingRule {
+	for i := range s.rules {
+		if s.rules[i].matches(spanName) {
+			return &s.rules[i]
+		}
+	}
+	return nil
+}
+
+// rootSampler samples the root spans by the matching rule or the default ratio
+type rootSampler struct {
+	s *Sampler
+}
+
+func (r rootSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if rule := r.s.match(p.Name); rule != nil {
+		return rule.sampler.ShouldSample(p)
+	}
+
+	return r.s.ratio.Load().sampler.ShouldSample(p)
+}
+
+func (r rootSampler) Description() string {
+	return "rootSampler"
+}
+
+// errorSpanProcessor exports the spans recorded but not sampled of the traces with an error span, see SamplerOptions.SampleErrors.
+// Head sampling decides before the error occurs, so the spans are buffered per trace until its local root spans end,
+// they are forwarded to the next processor as sampled once a span of the trace ends with the error status.
+// Only the spans of this process are kept, the other services of the trace decided on their own.
+type errorSpanProcessor struct {
+	next sdktrace.SpanProcessor
+
+	mu     sync.Mutex
+	traces map[trace.TraceID]*errorSampledTrace
+}
+
+type errorSampledTrace struct {
+	// roots the number of the local root spans not ended yet
+	roots  int
+	failed bool
+	spans  []sdktrace.ReadOnlySpan
+}
+
+func newErrorSpanProcessor(next sdktrace.SpanProcessor) *errorSpanProcessor {
+	return &errorSpanProcessor{
+		next:   next,
+		traces: make(map[trace.TraceID]*errorSampledTrace),
+	}
+}
+
+// OnStart implements sdktrace.SpanProcessor
+func (p *errorSpanProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(ctx, s)
+	if s.SpanContext().IsSampled() || !isLocalRootSpan(s) {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	t, ok := p.traces[s.SpanContext().TraceID()]
+	if !ok {
+		if len(p.traces) >= maxErrorSampledTraces {
+			return
+		}
+		t = &errorSampledTrace{}
+		p.traces[s.SpanContext().TraceID()] = t
+	}
+	t.roots++
+}
+
+// OnEnd implements sdktrace.SpanProcessor
+func (p *errorSpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if s.SpanContext().IsSampled() {
+		p.next.OnEnd(s)
+		return
+	}
+
+	for _, span := range p.collect(s) {
+		p.next.OnEnd(sampledSpan{span})
+	}
+}
+
+// collect buffers the span and returns the spans to export
+func (p *errorSpanProcessor) collect(s sdktrace.ReadOnlySpan) []sdktrace.ReadOnlySpan {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	traceID := s.SpanContext().TraceID()
+	t, ok := p.traces[traceID]
+	if !ok {
+		return nil
+	}
+	if isLocalRootSpan(s) {
+		t.roots--
+		if t.roots <= 0 {
+			delete(p.traces, traceID)
+		}
+	}
+
+	if t.failed || s.Status().Code == otelcodes.Error {
+		t.failed = true
+		spans := append(t.spans, s)
+		t.spans = nil
+		return spans
+	}
+	if len(t.spans) < maxErrorSampledTraceSpans {
+		t.spans = append(t.spans, s)
+	}
+	return nil
+}
+
+// Shutdown implements sdktrace.SpanProcessor, the buffered spans are dropped
+func (p *errorSpanProcessor) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	p.traces = make(map[trace.TraceID]*errorSampledTrace)
+	p.mu.Unlock()
+
+	return p.next.Shutdown(ctx)
+}
+
+// ForceFlush implements sdktrace.SpanProcessor
+func (p *errorSpanProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// isLocalRootSpan check if the span is the first span of the trace in this process
+func isLocalRootSpan(s sdktrace.ReadOnlySpan) bool {
+	return !s.Parent().IsValid() || s.Parent().IsRemote()
+}
+
+// sampledSpan the span recorded but not sampled, flagged as sampled to be exported
+type sampledSpan struct {
+	sdktrace.ReadOnlySpan
+}
+
+func (s sampledSpan) SpanContext() trace.SpanContext {
+	sc := s.ReadOnlySpan.SpanContext()
+	return sc.WithTraceFlags(sc.TraceFlags().WithSampled(true))
+}
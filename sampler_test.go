@@ -0,0 +1,126 @@
+package connect
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSampler(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+
+	parentContext := func(sampled bool) context.Context {
+		var flags trace.TraceFlags
+		if sampled {
+			flags = trace.FlagsSampled
+		}
+		return trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    traceID,
+			SpanID:     spanID,
+			TraceFlags: flags,
+			Remote:     true,
+		}))
+	}
+
+	shouldSample := func(s sdktrace.Sampler, ctx context.Context, name string) bool {
+		result := s.ShouldSample(sdktrace.SamplingParameters{ParentContext: ctx, TraceID: traceID, Name: name})
+		return result.Decision == sdktrace.RecordAndSample
+	}
+
+	sampler := NewSampler(&SamplerOptions{
+		Ratio: 0,
+		Rules: []SamplingRule{
+			{SpanName: "grpc.health.v1.Health/Check", Ratio: 0, OverrideParent: true},
+			{SpanName: "pkg.Payment/*", Ratio: 1},
+		},
+	})
+
+	t.Run("follow the parent decision", func(t *testing.T) {
+		assert.True(t, shouldSample(sampler, parentContext(true), "pkg.Service/Method"))
+		assert.False(t, shouldSample(sampler, parentContext(false), "pkg.Payment/Pay"))
+	})
+
+	t.Run("root spans use the rule ratio", func(t *testing.T) {
+		assert.True(t, shouldSample(sampler, context.Background(), "pkg.Payment/Pay"))
+		assert.False(t, shouldSample(sampler, context.Background(), "pkg.Service/Method"))
+	})
+
+	t.Run("rule overrides the parent decision", func(t *testing.T) {
+		assert.False(t, shouldSample(sampler, parentContext(true), "grpc.health.v1.Health/Check"))
+	})
+
+	t.Run("update the ratio at runtime", func(t *testing.T) {
+		sampler.SetRatio(1)
+		defer sampler.SetRatio(0)
+
+		assert.Equal(t, float64(1), sampler.Ratio())
+		assert.True(t, shouldSample(sampler, context.Background(), "pkg.Service/Method"))
+		assert.False(t, shouldSample(sampler, parentContext(false), "pkg.Service/Method"))
+	})
+
+	t.Run("record the spans not sampled", func(t *testing.T) {
+		sampler := NewSampler(&SamplerOptions{
+			Ratio:        0,
+			Rules:        []SamplingRule{{SpanName: "grpc.health.v1.Health/Check", Ratio: 0, OverrideParent: true}},
+			SampleErrors: true,
+		})
+		decision := func(ctx context.Context, name string) sdktrace.SamplingDecision {
+			return sampler.ShouldSample(sdktrace.SamplingParameters{ParentContext: ctx, TraceID: traceID, Name: name}).Decision
+		}
+
+		assert.Equal(t, sdktrace.RecordOnly, decision(context.Background(), "pkg.Service/Method"))
+		assert.Equal(t, sdktrace.RecordOnly, decision(parentContext(false), "pkg.Service/Method"))
+		assert.Equal(t, sdktrace.RecordAndSample, decision(parentContext(true), "pkg.Service/Method"))
+		assert.Equal(t, sdktrace.Drop, decision(parentContext(true), "grpc.health.v1.Health/Check"))
+
+		droppedParent := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID: traceID,
+			SpanID:  spanID,
+		}))
+		assert.Equal(t, sdktrace.Drop, decision(droppedParent, "pkg.Service/Method"))
+	})
+}
+
+func TestInitTelemetry_sampleErrors(t *testing.T) {
+	restoreGlobalProviders(t)
+
+	exporter := inMemoryExporter{tracetest.NewInMemoryExporter()}
+	traceRatio := 0.0
+	shutdown, err := InitTelemetry(context.Background(), &TelemetryOptions{
+		ServiceName:  "service",
+		SpanExporter: exporter,
+		TraceRatio:   &traceRatio,
+		SampleErrors: true,
+	})
+	require.NoError(t, err)
+
+	tracer := otel.Tracer("test")
+	ctx, parent := tracer.Start(context.Background(), "ok-parent")
+	_, child := tracer.Start(ctx, "ok-child")
+	child.End()
+	parent.End()
+
+	ctx, parent = tracer.Start(context.Background(), "failed-parent")
+	_, sibling := tracer.Start(ctx, "sibling")
+	sibling.End()
+	_, child = tracer.Start(ctx, "failed-child")
+	child.SetStatus(otelcodes.Error, "failed")
+	child.End()
+	parent.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	var names []string
+	for _, span := range exporter.GetSpans() {
+		assert.True(t, span.SpanContext.IsSampled())
+		names = append(names, span.Name)
+	}
+	assert.ElementsMatch(t, []string{"failed-parent", "sibling", "failed-child"}, names)
+}
@@ -0,0 +1,438 @@
+package connect
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/imdario/mergo"
+
+	"github.com/jpillora/backoff"
+	log "github.com/sirupsen/logrus"
+	"github.com/uptrace/opentelemetry-go-extra/otelgorm"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+// SQLConnectionOptions options for the SQL connection
+type SQLConnectionOptions struct {
+	PingInterval     time.Duration
+	RetryAttempts    int
+	MaxIdleConns     int
+	MaxOpenConns     int
+	ConnMaxLifetime  time.Duration
+	LogLevel         string
+	UseOpenTelemetry bool
+	PingTimeout      time.Duration
+	UseMetrics       bool // flag if the connection will record sql.DBStats metrics
+
+	// ReconnectCallback func will provide new *gorm.DB if current connection is broken and re-creation of new connection is succeeded.
+	ReconnectCallback func(db *gorm.DB)
+
+	// HealthCallback func will be called when the health changes, err is nil when the connection is healthy again.
+	HealthCallback func(err error)
+
+	// ReplicaDSNs reads are routed to the healthy replicas, writes and transactions go to the primary, see ForcePrimary.
+	ReplicaDSNs []string
+
+	// SlowThreshold the statements taking longer are logged as warnings.
+	// Default is 200 milliseconds, negative disables the slow statement logs
+	SlowThreshold time.Duration
+
+	// LogParameters flag if the bound parameters are logged in the SQL, the values of the SensitiveColumns are redacted
+	LogParameters bool
+
+	// SensitiveColumns the values of the columns containing any of them, case insensitive, are redacted from the logs.
+	// Default is password, secret and token
+	SensitiveColumns []string
+}
+
+// SQLDialect opens the gorm connections of a database system
+type SQLDialect struct {
+	// Name the database system, e.g. postgresql, used in the logs and the metrics
+	Name string
+
+	// DriverName the database/sql driver of the replicas, e.g. pgx
+	DriverName string
+
+	// Open returns the dialector of the DSN, e.g. postgres.Open
+	Open func(dsn string) gorm.Dialector
+
+	// OpenConn returns the dialector of an opened replica connection pool, e.g. postgres.New(postgres.Config{Conn: conn})
+	OpenConn func(conn gorm.ConnPool) gorm.Dialector
+}
+
+var (
+	defaultSQLConnectionOptions = &SQLConnectionOptions{
+		PingInterval:     1 * time.Second,
+		RetryAttempts:    5,
+		MaxIdleConns:     2,
+		MaxOpenConns:     5,
+		ConnMaxLifetime:  1 * time.Hour,
+		LogLevel:         "info",
+		UseOpenTelemetry: false,
+		PingTimeout:      5 * time.Second,
+		SlowThreshold:    defaultSlowThreshold,
+		SensitiveColumns: defaultSensitiveColumns,
+	}
+
+	mysqlDialect = &SQLDialect{
+		Name:       "mysql",
+		DriverName: "mysql",
+		Open:       mysql.Open,
+		OpenConn: func(conn gorm.ConnPool) gorm.Dialector {
+			return mysql.New(mysql.Config{Conn: conn, SkipInitializeWithVersion: true})
+		},
+	}
+
+	postgresDialect = &SQLDialect{
+		Name:       "postgresql",
+		DriverName: "pgx",
+		Open:       postgres.Open,
+		OpenConn: func(conn gorm.ConnPool) gorm.Dialector {
+			return postgres.New(postgres.Config{Conn: conn})
+		},
+	}
+
+	// cockroachDialect CockroachDB speaks the PostgreSQL wire protocol
+	cockroachDialect = &SQLDialect{
+		Name:       "cockroachdb",
+		DriverName: postgresDialect.DriverName,
+		Open:       postgresDialect.Open,
+		OpenConn:   postgresDialect.OpenConn,
+	}
+)
+
+// SQLConnector connection to a SQL database, the connection is checked every PingInterval
+// and replaced when it's broken, so always get it from DB instead of keeping it
+type SQLConnector struct {
+	databaseDSN string
+	dialect     *SQLDialect
+	options     *SQLConnectionOptions
+	replicas    *replicaSet
+	db          atomic.Pointer[gorm.DB]
+
+	// cancel stops the health check, done is closed when it exits
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// onReconnectFailed is called when the reconnection is exhausted,
+	// the broken connection is only logged without reconnecting when it's nil
+	onReconnectFailed func(err error)
+}
+
+// NewSQLConnector connects to the database with the dialect and starts the health check.
+// The initial connection is retried with backoff up to RetryAttempts times or until the ctx is done.
+func NewSQLConnector(ctx context.Context, databaseDSN string, dialect *SQLDialect, opt *SQLConnectionOptions) (*SQLConnector, error) {
+	if dialect == nil || dialect.Open == nil || dialect.OpenConn == nil {
+		return nil, errors.New("dialect must have Open and OpenConn")
+	}
+
+	options := applySQLConnectionOptions(opt)
+	if err := options.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s connection options: %w", dialect.Name, err)
+	}
+
+	connector, err := newSQLConnector(ctx, databaseDSN, dialect, options, func(err error) {
+		log.Error(err)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s database: %w", dialect.Name, err)
+	}
+	return connector, nil
+}
+
+// NewPostgreSQLConnector connects to the PostgreSQL database and starts the health check, see NewSQLConnector
+func NewPostgreSQLConnector(ctx context.Context, databaseDSN string, opt *SQLConnectionOptions) (*SQLConnector, error) {
+	return NewSQLConnector(ctx, databaseDSN, postgresDialect, opt)
+}
+
+// newSQLConnector connects with the validated options, onReconnectFailed is called when the reconnection is exhausted.
+// The broken connection is not reconnected when onReconnectFailed is nil, e.g. the caller keeping the connection can't receive it.
+func newSQLConnector(ctx context.Context, databaseDSN string, dialect *SQLDialect, options *SQLConnectionOptions, onReconnectFailed func(err error)) (*SQLConnector, error) {
+	c := &SQLConnector{
+		databaseDSN:       databaseDSN,
+		dialect:           dialect,
+		options:           options,
+		onReconnectFailed: onReconnectFailed,
+	}
+
+	if len(options.ReplicaDSNs) > 0 {
+		replicas, err := openReplicaSet(dialect.DriverName, options.ReplicaDSNs, dialect.OpenConn, sqlPoolOptions{
+			MaxIdleConns:    options.MaxIdleConns,
+			MaxOpenConns:    options.MaxOpenConns,
+			ConnMaxLifetime: options.ConnMaxLifetime,
+			PingTimeout:     options.PingTimeout,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to open replicas: %w", err)
+		}
+		c.replicas = replicas
+	}
+
+	conn, err := c.connectWithRetry(ctx)
+	if err != nil {
+		if c.replicas != nil {
+			_ = c.replicas.close() // keep the connection error
+		}
+		return nil, err
+	}
+	c.db.Store(conn)
+
+	if options.UseMetrics {
+		if err := registerDBStatsMetrics(dialect.Name, func() (*sql.DB, error) { return c.DB().DB() }); err != nil {
+			log.WithField("dbSystem", dialect.Name).Error("failed to register metrics: ", err)
+		}
+	}
+
+	c.startHealthCheck()
+
+	log.WithField("dbSystem", dialect.Name).Info("Connection to database success...")
+	return c, nil
+}
+
+// DB returns the current connection, it's safe for concurrent use
+func (c *SQLConnector) DB() *gorm.DB {
+	return c.db.Load()
+}
+
+// Stop stops the health check without waiting for it to exit, the connection is not closed
+func (c *SQLConnector) Stop() {
+	c.cancel()
+}
+
+// Close stops the health check, waits for it to exit and closes the connection and the replicas.
+// The running reconnection is interrupted, so the wait is bounded by a single connection attempt.
+// ctx.Err() is returned when the ctx is done first, e.g. use the termination grace period as its deadline.
+func (c *SQLConnector) Close(ctx context.Context) error {
+	c.Stop()
+
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	var errs []error
+	db, err := c.DB().DB()
+	if err == nil {
+		err = db.Close()
+	}
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to close %s database: %w", c.dialect.Name, err))
+	}
+	if c.replicas != nil {
+		errs = append(errs, c.replicas.close())
+	}
+	return errors.Join(errs...)
+}
+
+// startHealthCheck runs checkConnection until Stop is called
+func (c *SQLConnector) startHealthCheck() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.done = make(chan struct{})
+
+	go func() {
+		defer close(c.done)
+		c.checkConnection(ctx, time.NewTicker(c.options.PingInterval))
+	}()
+}
+
+//gocognit:ignore
+func (c *SQLConnector) checkConnection(ctx context.Context, ticker *time.Ticker) {
+	healthy := true
+	setHealth := func(err error) {
+		if (err == nil) == healthy {
+			return
+		}
+		healthy = err == nil
+		if c.options.HealthCallback != nil {
+			c.options.HealthCallback(err)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			ticker.Stop()
+			return
+		case <-ticker.C:
+			if c.replicas != nil {
+				c.replicas.checkHealth(ctx)
+			}
+
+			if err := c.ping(ctx); err != nil {
+				if ctx.Err() != nil {
+					continue // stopped while pinging
+				}
+				log.WithField("dbSystem", c.dialect.Name).Error("ping to db got err: ", err)
+				setHealth(err)
+				if c.onReconnectFailed == nil {
+					continue
+				}
+
+				if err = c.reconnect(ctx); err != nil {
+					if ctx.Err() == nil {
+						c.onReconnectFailed(err)
+					}
+					continue
+				}
+			}
+			setHealth(nil)
+		}
+	}
+}
+
+func (c *SQLConnector) ping(ctx context.Context) error {
+	db, err := c.DB().DB()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancelFunc := context.WithTimeout(ctx, c.options.PingTimeout)
+	defer cancelFunc()
+	return db.PingContext(ctx)
+}
+
+// reconnect replaces the current connection and closes the previous one, the ReconnectCallback is called on success.
+// The retries stop when the ctx is done.
+func (c *SQLConnector) reconnect(ctx context.Context) error {
+	log.WithField("dbSystem", c.dialect.Name).Info("reconnecting to db")
+	conn, err := c.connectWithRetry(ctx)
+	if err != nil {
+		return err
+	}
+
+	// get the previous pool before the callback, e.g. InitializeCockroachConn copies the connection into the previous one
+	previous, previousErr := c.DB().DB()
+
+	log.WithField("dbSystem", c.dialect.Name).Info("db connected")
+	c.db.Store(conn)
+	if c.options.ReconnectCallback != nil {
+		c.options.ReconnectCallback(conn)
+	}
+
+	if previousErr == nil {
+		if err := previous.Close(); err != nil {
+			log.WithField("dbSystem", c.dialect.Name).Error("failed to close the previous connection: ", err)
+		}
+	}
+	return nil
+}
+
+// connectWithRetry opens a new connection with backoff up to RetryAttempts times or until the ctx is done
+func (c *SQLConnector) connectWithRetry(ctx context.Context) (*gorm.DB, error) {
+	b := backoff.Backoff{
+		Factor: 2,
+		Jitter: true,
+		Min:    100 * time.Millisecond,
+		Max:    1 * time.Second,
+	}
+
+	var lastErr error
+	for b.Attempt() < float64(max(c.options.RetryAttempts, 1)) {
+		conn, err := c.open()
+		if err == nil {
+			return conn, nil
+		}
+		log.WithFields(log.Fields{
+			"dbSystem":    c.dialect.Name,
+			"databaseDSN": c.databaseDSN,
+		}).Error("failed to connect to database: ", err)
+		lastErr = err
+
+		select {
+		case <-ctx.Done():
+			return nil, errors.Join(ctx.Err(), lastErr)
+		case <-time.After(b.Duration()):
+		}
+	}
+
+	return nil, fmt.Errorf("maximum retry to connect database: %w", lastErr)
+}
+
+func (c *SQLConnector) open() (*gorm.DB, error) {
+	conn, err := gorm.Open(c.dialect.Open(c.databaseDSN), &gorm.Config{
+		Logger: newGormLogger(c.options),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if c.options.UseOpenTelemetry {
+		if err := conn.Use(otelgorm.NewPlugin()); err != nil {
+			return nil, err
+		}
+	}
+
+	db, err := conn.DB()
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxIdleConns(c.options.MaxIdleConns)
+	db.SetConnMaxLifetime(c.options.ConnMaxLifetime)
+	db.SetMaxOpenConns(c.options.MaxOpenConns)
+
+	if c.replicas != nil {
+		if err := c.replicas.register(conn); err != nil {
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// gormLogLevel converts the LogLevel option, info is also used for the empty level
+func gormLogLevel(level string) gormLogger.LogLevel {
+	switch level {
+	case "error":
+		return gormLogger.Error
+	case "warn":
+		return gormLogger.Warn
+	default:
+		return gormLogger.Info
+	}
+}
+
+// isValidGormLogLevel check if the level is handled by gormLogLevel
+func isValidGormLogLevel(level string) bool {
+	switch level {
+	case "", "info", "warn", "error":
+		return true
+	default:
+		return false
+	}
+}
+
+// Validate returns the joined errors of every invalid field
+func (o *SQLConnectionOptions) Validate() error {
+	v := &optionsValidator{}
+	v.check(o.PingInterval > 0, "PingInterval", "must be positive")
+	v.check(o.RetryAttempts >= 0, "RetryAttempts", "must not be negative")
+	v.check(o.MaxIdleConns >= 0, "MaxIdleConns", "must not be negative")
+	v.check(o.MaxOpenConns >= 0, "MaxOpenConns", "must not be negative")
+	v.check(o.MaxOpenConns <= 0 || o.MaxIdleConns <= o.MaxOpenConns, "MaxIdleConns", "must not be greater than MaxOpenConns")
+	v.check(o.ConnMaxLifetime >= 0, "ConnMaxLifetime", "must not be negative")
+	v.check(isValidGormLogLevel(o.LogLevel), "LogLevel", fmt.Sprintf("unknown log level %q, must be one of info, warn or error", o.LogLevel))
+	v.check(o.PingTimeout > 0, "PingTimeout", "must be positive")
+	for i, dsn := range o.ReplicaDSNs {
+		v.check(dsn != "", fmt.Sprintf("ReplicaDSNs[%d]", i), "must not be empty")
+	}
+	return v.err()
+}
+
+func applySQLConnectionOptions(opt *SQLConnectionOptions) *SQLConnectionOptions {
+	if opt == nil {
+		return defaultSQLConnectionOptions
+	}
+
+	// if error occurs, also return options from input
+	_ = mergo.Merge(opt, *defaultSQLConnectionOptions)
+	return opt
+}
@@ -0,0 +1,213 @@
+package connect
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// newFakeSQLDialect opens the connections with a fakeTxDriver, whatever the DSN of the connector
+func newFakeSQLDialect(t *testing.T) *SQLDialect {
+	dsn := registerFakeTxDriver(t, &fakeTxDriver{})
+
+	return &SQLDialect{
+		Name:       "fake",
+		DriverName: fakeTxDriverName,
+		Open: func(_ string) gorm.Dialector {
+			return postgres.New(postgres.Config{DriverName: fakeTxDriverName, DSN: dsn})
+		},
+		OpenConn: postgresDialect.OpenConn,
+	}
+}
+
+func TestNewSQLConnector(t *testing.T) {
+	t.Run("connect and stop", func(t *testing.T) {
+		connector, err := NewSQLConnector(context.Background(), "fake", newFakeSQLDialect(t), &SQLConnectionOptions{LogLevel: "error"})
+		require.NoError(t, err)
+		require.NotNil(t, connector.DB())
+		assert.NoError(t, connector.ping(context.Background()))
+
+		connector.Stop()
+		connector.Stop() // stopping twice must not panic
+	})
+
+	t.Run("return the connection error", func(t *testing.T) {
+		connector, err := NewPostgreSQLConnector(context.Background(), unreachableCockroachDSN, &SQLConnectionOptions{RetryAttempts: 1})
+		assert.ErrorContains(t, err, "failed to connect to postgresql database: maximum retry to connect database")
+		assert.Nil(t, connector)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		_, err := NewPostgreSQLConnector(context.Background(), unreachableCockroachDSN, &SQLConnectionOptions{MaxOpenConns: -1})
+		assert.ErrorContains(t, err, "invalid postgresql connection options")
+	})
+
+	t.Run("invalid dialect", func(t *testing.T) {
+		_, err := NewSQLConnector(context.Background(), "fake", &SQLDialect{Name: "fake"}, nil)
+		assert.ErrorContains(t, err, "dialect must have Open and OpenConn")
+	})
+}
+
+func TestSQLConnector_checkConnection(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		healthErrs  []error
+		reconnected = make(chan *gorm.DB, 1)
+	)
+
+	connector, err := newSQLConnector(context.Background(), "fake", newFakeSQLDialect(t), applySQLConnectionOptions(&SQLConnectionOptions{
+		PingInterval: 10 * time.Millisecond,
+		LogLevel:     "error",
+		HealthCallback: func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			healthErrs = append(healthErrs, err)
+		},
+		ReconnectCallback: func(db *gorm.DB) { reconnected <- db },
+	}), func(err error) { t.Error(err) })
+	require.NoError(t, err)
+	defer connector.Stop()
+
+	broken := connector.DB()
+	db, err := broken.DB()
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	select {
+	case conn := <-reconnected:
+		assert.NotSame(t, broken, conn)
+		assert.Eventually(t, func() bool { return connector.DB() == conn }, time.Second, 10*time.Millisecond)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the broken connection is not reconnected")
+	}
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(healthErrs) == 2
+	}, time.Second, 10*time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.ErrorContains(t, healthErrs[0], "database is closed")
+	assert.NoError(t, healthErrs[1])
+}
+
+func TestSQLConnector_checkConnectionWithoutReconnect(t *testing.T) {
+	unhealthy := make(chan error, 1)
+	connector, err := newSQLConnector(context.Background(), "fake", newFakeSQLDialect(t), applySQLConnectionOptions(&SQLConnectionOptions{
+		PingInterval:   10 * time.Millisecond,
+		LogLevel:       "error",
+		HealthCallback: func(err error) { unhealthy <- err },
+	}), nil)
+	require.NoError(t, err)
+	defer connector.Stop()
+
+	broken := connector.DB()
+	db, err := broken.DB()
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	select {
+	case err := <-unhealthy:
+		assert.ErrorContains(t, err, "database is closed")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the broken connection is not reported")
+	}
+
+	// the broken connection is only logged on the next pings
+	time.Sleep(50 * time.Millisecond)
+	assert.Same(t, broken, connector.DB())
+}
+
+func TestSQLConnector_reconnect(t *testing.T) {
+	current := &gorm.DB{}
+	reconnected := false
+
+	connector := &SQLConnector{
+		databaseDSN: unreachableCockroachDSN,
+		dialect:     cockroachDialect,
+		options: applySQLConnectionOptions(&SQLConnectionOptions{
+			RetryAttempts:     1,
+			ReconnectCallback: func(_ *gorm.DB) { reconnected = true },
+		}),
+	}
+	connector.db.Store(current)
+
+	err := connector.reconnect(context.Background())
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "maximum retry to connect database")
+	assert.False(t, reconnected)
+	assert.Same(t, current, connector.DB())
+}
+
+func TestSQLConnector_reconnectClosePrevious(t *testing.T) {
+	connector, err := newSQLConnector(context.Background(), "fake", newFakeSQLDialect(t), applySQLConnectionOptions(&SQLConnectionOptions{
+		PingInterval: time.Hour,
+		LogLevel:     "error",
+	}), func(err error) { t.Error(err) })
+	require.NoError(t, err)
+	defer connector.Stop()
+
+	previous, err := connector.DB().DB()
+	require.NoError(t, err)
+
+	require.NoError(t, connector.reconnect(context.Background()))
+
+	assert.ErrorContains(t, previous.Ping(), "database is closed")
+	assert.NoError(t, connector.ping(context.Background()))
+}
+
+func TestSQLConnector_Close(t *testing.T) {
+	t.Run("close the connection", func(t *testing.T) {
+		connector, err := NewSQLConnector(context.Background(), "fake", newFakeSQLDialect(t), &SQLConnectionOptions{LogLevel: "error"})
+		require.NoError(t, err)
+
+		require.NoError(t, connector.Close(context.Background()))
+		assert.ErrorContains(t, connector.ping(context.Background()), "database is closed")
+
+		select {
+		case <-connector.done:
+		default:
+			t.Fatal("the health check is still running")
+		}
+	})
+
+	t.Run("interrupt the reconnection", func(t *testing.T) {
+		var unreachable atomic.Bool
+		dialect := newFakeSQLDialect(t)
+		openFake := dialect.Open
+		dialect.Open = func(dsn string) gorm.Dialector {
+			if unreachable.Load() {
+				return postgres.Open(unreachableCockroachDSN)
+			}
+			return openFake(dsn)
+		}
+
+		connector, err := newSQLConnector(context.Background(), "fake", dialect, applySQLConnectionOptions(&SQLConnectionOptions{
+			PingInterval:  10 * time.Millisecond,
+			RetryAttempts: 100,
+			LogLevel:      "error",
+		}), func(err error) { t.Error(err) })
+		require.NoError(t, err)
+
+		// break the connection, the reconnection keeps failing on the unreachable database
+		unreachable.Store(true)
+		db, err := connector.DB().DB()
+		require.NoError(t, err)
+		require.NoError(t, db.Close())
+
+		time.Sleep(100 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		assert.NoError(t, connector.Close(ctx))
+	})
+}
@@ -0,0 +1,142 @@
+package connect
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// ForcePrimary routes the queries of db to the primary, e.g. to read after write.
+// Writes and transactions always go to the primary.
+func ForcePrimary(db *gorm.DB) *gorm.DB {
+	return db.Clauses(dbresolver.Write)
+}
+
+// sqlPoolOptions connection pool options shared by the primary and the replicas
+type sqlPoolOptions struct {
+	MaxIdleConns    int
+	MaxOpenConns    int
+	ConnMaxLifetime time.Duration
+	PingTimeout     time.Duration
+}
+
+// replicaSet read replicas of a connection, the unhealthy replicas are dropped from the rotation until they recover.
+// The replicas are opened once and shared by the reconnected primary connections.
+type replicaSet struct {
+	replicas    []*replica
+	dialector   func(conn gorm.ConnPool) gorm.Dialector
+	pingTimeout time.Duration
+}
+
+type replica struct {
+	dsn     string
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// replicaConnPool hides the Ping of the replica from gorm.Open,
+// so a replica that is down when the connection is opened joins the rotation once it recovers
+type replicaConnPool struct {
+	gorm.ConnPool
+	replica *replica
+}
+
+// openReplicaSet opens the replicas with the driver and checks their health once
+func openReplicaSet(driverName string, dsns []string, dialector func(conn gorm.ConnPool) gorm.Dialector, options sqlPoolOptions) (*replicaSet, error) {
+	s := &replicaSet{dialector: dialector, pingTimeout: options.PingTimeout}
+	for _, dsn := range dsns {
+		db, err := sql.Open(driverName, dsn)
+		if err != nil {
+			_ = s.close() // keep the open error
+			return nil, err
+		}
+		db.SetMaxIdleConns(options.MaxIdleConns)
+		db.SetConnMaxLifetime(options.ConnMaxLifetime)
+		db.SetMaxOpenConns(options.MaxOpenConns)
+
+		s.replicas = append(s.replicas, &replica{dsn: dsn, db: db})
+	}
+
+	s.checkHealth(context.Background())
+	return s, nil
+}
+
+// register routes the reads of the primary connection to the healthy replicas
+func (s *replicaSet) register(primary *gorm.DB) error {
+	dialectors := make([]gorm.Dialector, 0, len(s.replicas)+1)
+	for _, r := range s.replicas {
+		dialectors = append(dialectors, s.dialector(&replicaConnPool{ConnPool: r.db, replica: r}))
+	}
+	// dbresolver skips the policy when there is a single replica, the primary keeps the policy in charge of the fallback
+	dialectors = append(dialectors, s.dialector(primary.ConnPool))
+
+	return primary.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: dialectors,
+		Policy:   &replicaPolicy{primary: primary.ConnPool},
+	}))
+}
+
+// checkHealth pings every replica concurrently and updates their health
+func (s *replicaSet) checkHealth(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, r := range s.replicas {
+		wg.Add(1)
+		go func(r *replica) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, s.pingTimeout)
+			defer cancel()
+
+			err := r.db.PingContext(ctx)
+			healthy := err == nil
+			if r.healthy.Swap(healthy) == healthy {
+				return
+			}
+			if healthy {
+				log.WithField("replicaDSN", r.dsn).Info("replica is back to the rotation")
+				return
+			}
+			log.WithField("replicaDSN", r.dsn).Error("replica is dropped from the rotation: ", err)
+		}(r)
+	}
+	wg.Wait()
+}
+
+func (s *replicaSet) close() error {
+	var errs []error
+	for _, r := range s.replicas {
+		if err := r.db.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close replica %s: %w", r.dsn, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// replicaPolicy picks a random healthy replica, the primary is used when every replica is unhealthy
+type replicaPolicy struct {
+	primary gorm.ConnPool
+}
+
+// Resolve implements dbresolver.Policy
+func (p *replicaPolicy) Resolve(connPools []gorm.ConnPool) gorm.ConnPool {
+	healthy := make([]gorm.ConnPool, 0, len(connPools))
+	for _, pool := range connPools {
+		if rp, ok := pool.(*replicaConnPool); ok && rp.replica.healthy.Load() {
+			healthy = append(healthy, pool)
+		}
+	}
+
+	if len(healthy) == 0 {
+		return p.primary
+	}
+	return healthy[rand.IntN(len(healthy))] //nolint:gosec
+}
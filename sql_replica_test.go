@@ -0,0 +1,86 @@
+package connect
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestReplicaSet(t *testing.T) {
+	db, primary := newFakeTxDB(t, nil)
+
+	replicaDriver := &fakeTxDriver{}
+	replicaDSN := registerFakeTxDriver(t, replicaDriver)
+
+	replicas, err := openReplicaSet(fakeTxDriverName, []string{replicaDSN}, func(conn gorm.ConnPool) gorm.Dialector {
+		return postgres.New(postgres.Config{Conn: conn})
+	}, sqlPoolOptions{PingTimeout: time.Second})
+	require.NoError(t, err)
+	defer func() { _ = replicas.close() }()
+	require.NoError(t, replicas.register(db))
+
+	query := func(db *gorm.DB) {
+		var rows []map[string]interface{}
+		require.NoError(t, db.Table("accounts").Find(&rows).Error)
+	}
+	reset := func() {
+		primary.statements = nil
+		replicaDriver.statements = nil
+	}
+
+	t.Run("route reads to the replica", func(t *testing.T) {
+		reset()
+		query(db)
+		assert.Equal(t, []string{`SELECT * FROM "accounts"`}, replicaDriver.statements)
+		assert.Empty(t, primary.statements)
+	})
+
+	t.Run("route writes to the primary", func(t *testing.T) {
+		reset()
+		require.NoError(t, db.Exec("UPDATE accounts SET balance = 1").Error)
+		assert.Equal(t, []string{"UPDATE accounts SET balance = 1"}, primary.statements)
+		assert.Empty(t, replicaDriver.statements)
+	})
+
+	t.Run("force the primary", func(t *testing.T) {
+		reset()
+		query(ForcePrimary(db))
+		assert.Equal(t, []string{`SELECT * FROM "accounts"`}, primary.statements)
+		assert.Empty(t, replicaDriver.statements)
+	})
+
+	t.Run("drop the unhealthy replica", func(t *testing.T) {
+		reset()
+		replicas.replicas[0].healthy.Store(false)
+		defer replicas.replicas[0].healthy.Store(true)
+
+		query(db)
+		assert.Equal(t, []string{`SELECT * FROM "accounts"`}, primary.statements)
+		assert.Empty(t, replicaDriver.statements)
+	})
+
+	t.Run("health check", func(t *testing.T) {
+		replicas.replicas[0].healthy.Store(false)
+		replicas.checkHealth(context.Background())
+		assert.True(t, replicas.replicas[0].healthy.Load())
+	})
+}
+
+func TestReplicaPolicy_Resolve(t *testing.T) {
+	primary := &sql.DB{}
+	healthy := &replicaConnPool{replica: &replica{}}
+	healthy.replica.healthy.Store(true)
+	unhealthy := &replicaConnPool{replica: &replica{}}
+
+	p := &replicaPolicy{primary: primary}
+	for i := 0; i < 10; i++ {
+		assert.Same(t, healthy, p.Resolve([]gorm.ConnPool{unhealthy, healthy, primary}))
+	}
+	assert.Same(t, primary, p.Resolve([]gorm.ConnPool{unhealthy, primary}))
+}
@@ -0,0 +1,394 @@
+package connect
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/imdario/mergo"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutlog"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	logglobal "go.opentelemetry.io/otel/log/global"
+	"go.opentelemetry.io/otel/propagation"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"google.golang.org/grpc/credentials"
+)
+
+// TelemetryExporter the protocol used to export the telemetry
+type TelemetryExporter string
+
+// TelemetryExporter list
+const (
+	TelemetryExporterOTLPGRPC TelemetryExporter = "otlpgrpc"
+	TelemetryExporterOTLPHTTP TelemetryExporter = "otlphttp"
+	TelemetryExporterStdout   TelemetryExporter = "stdout"
+)
+
+// environment variables of the kubernetes resource attributes, usually set from the downward API
+const (
+	k8sPodNameEnv       = "K8S_POD_NAME"
+	k8sNamespaceNameEnv = "K8S_NAMESPACE_NAME"
+	k8sNodeNameEnv      = "K8S_NODE_NAME"
+)
+
+// TelemetryOptions options for the telemetry bootstrap
+type TelemetryOptions struct {
+	ServiceName    string
+	ServiceVersion string
+	Environment    string
+
+	// ResourceAttributes additional resource attributes, the host and the kubernetes pod
+	// (K8S_POD_NAME, K8S_NAMESPACE_NAME and K8S_NODE_NAME env) are always detected
+	ResourceAttributes map[string]string
+
+	// Exporter the protocol used to export the telemetry, default is TelemetryExporterOTLPGRPC
+	Exporter TelemetryExporter
+
+	// Endpoint the collector host and port, default is the OTLP exporter default
+	Endpoint string
+
+	// Insecure disables the client transport security of the OTLP exporter
+	Insecure bool
+
+	// TLSConfig the client TLS config of the OTLP exporter, default is the system TLS config
+	TLSConfig *tls.Config
+
+	// Headers sent with every OTLP export request, e.g. the collector access token
+	Headers map[string]string
+
+	// Writer the output of the stdout exporter, default is os.Stdout
+	Writer io.Writer
+
+	// TraceRatio the ratio of sampled root spans, default is 1 when nil, 0 never samples.
+	// The spans with a parent follow the parent decision.
+	TraceRatio *float64
+
+	// SamplingRules the sampling rules per span name, see SamplingRule
+	SamplingRules []SamplingRule
+
+	// SampleErrors flag if the spans of this process are exported for the traces with an error span,
+	// even when the trace is not sampled, see SamplerOptions.SampleErrors.
+	// The spans not sampled are recorded and buffered until their trace ends, which costs memory and CPU.
+	SampleErrors bool
+
+	// Sampler overrides the sampler built from TraceRatio, SamplingRules and SampleErrors,
+	// e.g. a shared NewSampler to update the ratio at runtime. It must be built with SampleErrors when SampleErrors is true.
+	Sampler sdktrace.Sampler
+
+	// SpanExporter overrides the span exporter, e.g. tracetest.NewInMemoryExporter() for tests
+	SpanExporter sdktrace.SpanExporter
+
+	// UseMetrics flag if the meter provider will be set up
+	UseMetrics bool
+
+	// MetricInterval the interval of the metric export, default is 1 minute
+	MetricInterval time.Duration
+
+	// MetricReader overrides the periodic metric reader, e.g. sdkmetric.NewManualReader() for tests
+	MetricReader sdkmetric.Reader
+
+	// UseLogs flag if the logger provider will be set up
+	UseLogs bool
+
+	// LogExporter overrides the log exporter, e.g. for tests
+	LogExporter sdklog.Exporter
+}
+
+var defaultTelemetryOptions = &TelemetryOptions{
+	Exporter:       TelemetryExporterOTLPGRPC,
+	MetricInterval: time.Minute,
+	Writer:         os.Stdout,
+}
+
+// telemetry the providers set up by initTelemetry
+type telemetry struct {
+	tracerProvider *sdktrace.TracerProvider
+	shutdownFuncs  []func(context.Context) error
+}
+
+// shutdown flushes and shuts down every provider, in reverse order of setup
+func (t *telemetry) shutdown(ctx context.Context) error {
+	var errs []error
+	for i := len(t.shutdownFuncs) - 1; i >= 0; i-- {
+		errs = append(errs, t.shutdownFuncs[i](ctx))
+	}
+	t.shutdownFuncs = nil
+	return errors.Join(errs...)
+}
+
+// InitTelemetry sets up the global OpenTelemetry tracer provider and propagator, also the meter and logger provider when enabled.
+// The returned shutdown func flushes the pending telemetry and must be called before the application exits.
+func InitTelemetry(ctx context.Context, opt *TelemetryOptions) (shutdown func(context.Context) error, err error) {
+	options := applyTelemetryOptions(opt)
+	if err := options.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid telemetry options: %w", err)
+	}
+
+	t, err := initTelemetry(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.shutdown, nil
+}
+
+// Validate returns the joined errors of every invalid field
+func (o *TelemetryOptions) Validate() error {
+	v := &optionsValidator{}
+	switch o.Exporter {
+	case TelemetryExporterOTLPGRPC, TelemetryExporterOTLPHTTP, TelemetryExporterStdout:
+	default:
+		v.check(false, "Exporter", fmt.Sprintf("unknown exporter %q", o.Exporter))
+	}
+	v.check(!o.Insecure || o.TLSConfig == nil, "TLSConfig", "must not be set when Insecure is true")
+	v.check(o.TraceRatio == nil || (*o.TraceRatio >= 0 && *o.TraceRatio <= 1), "TraceRatio", "must be between 0 and 1")
+	v.check(o.MetricInterval > 0, "MetricInterval", "must be positive")
+	return v.err()
+}
+
+func initTelemetry(ctx context.Context, opts *TelemetryOptions) (_ *telemetry, err error) {
+	// the resource is partially detected on error, e.g. when the host detection fails
+	res, err := newTelemetryResource(ctx, opts)
+	if err != nil {
+		log.Warnf("could not set the telemetry resource: %v", err)
+	}
+
+	// shut down the providers already set up when the later setup fails
+	t := &telemetry{}
+	defer func() {
+		if err != nil {
+			_ = t.shutdown(ctx)
+		}
+	}()
+
+	spanExporter := opts.SpanExporter
+	if spanExporter == nil {
+		spanExporter, err = newSpanExporter(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create span exporter: %w", err)
+		}
+	}
+
+	sampler := opts.Sampler
+	if sampler == nil {
+		ratio := 1.0
+		if opts.TraceRatio != nil {
+			ratio = *opts.TraceRatio
+		}
+		sampler = NewSampler(&SamplerOptions{Ratio: ratio, Rules: opts.SamplingRules, SampleErrors: opts.SampleErrors})
+	}
+
+	var spanProcessor sdktrace.SpanProcessor = sdktrace.NewBatchSpanProcessor(spanExporter)
+	if opts.SampleErrors {
+		spanProcessor = newErrorSpanProcessor(spanProcessor)
+	}
+
+	t.tracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithSpanProcessor(spanProcessor),
+		sdktrace.WithResource(res),
+	)
+	t.shutdownFuncs = append(t.shutdownFuncs, t.tracerProvider.Shutdown)
+
+	if opts.UseMetrics {
+		reader := opts.MetricReader
+		if reader == nil {
+			metricExporter, err := newMetricExporter(ctx, opts)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create metric exporter: %w", err)
+			}
+			reader = sdkmetric.NewPeriodicReader(metricExporter, sdkmetric.WithInterval(opts.MetricInterval))
+		}
+
+		meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithResource(res))
+		t.shutdownFuncs = append(t.shutdownFuncs, meterProvider.Shutdown)
+		otel.SetMeterProvider(meterProvider)
+	}
+
+	if opts.UseLogs {
+		logExporter := opts.LogExporter
+		if logExporter == nil {
+			logExporter, err = newLogExporter(ctx, opts)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create log exporter: %w", err)
+			}
+		}
+
+		loggerProvider := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewBatchProcessor(logExporter)), sdklog.WithResource(res))
+		t.shutdownFuncs = append(t.shutdownFuncs, loggerProvider.Shutdown)
+		logglobal.SetLoggerProvider(loggerProvider)
+	}
+
+	otel.SetTracerProvider(t.tracerProvider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return t, nil
+}
+
+func newTelemetryResource(ctx context.Context, opts *TelemetryOptions) (*resource.Resource, error) {
+	// the empty attributes are skipped to not override the OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES env
+	var attrs []attribute.KeyValue
+	if opts.ServiceName != "" {
+		attrs = append(attrs, semconv.ServiceNameKey.String(opts.ServiceName))
+	}
+	if opts.Environment != "" {
+		attrs = append(attrs, semconv.DeploymentEnvironmentKey.String(opts.Environment))
+	}
+	if opts.ServiceVersion != "" {
+		attrs = append(attrs, semconv.ServiceVersionKey.String(opts.ServiceVersion))
+	}
+	for env, key := range map[string]attribute.Key{
+		k8sPodNameEnv:       semconv.K8SPodNameKey,
+		k8sNamespaceNameEnv: semconv.K8SNamespaceNameKey,
+		k8sNodeNameEnv:      semconv.K8SNodeNameKey,
+	} {
+		if value := os.Getenv(env); value != "" {
+			attrs = append(attrs, key.String(value))
+		}
+	}
+	for key, value := range opts.ResourceAttributes {
+		attrs = append(attrs, attribute.String(key, value))
+	}
+
+	return resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithHost(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(attrs...),
+	)
+}
+
+// otlpClientOptions the constructors of the OTLP client options of a signal and protocol
+type otlpClientOptions[T any] struct {
+	withHeaders   func(map[string]string) T
+	withEndpoint  func(string) T
+	withInsecure  func() T
+	withTLSConfig func(*tls.Config) T
+}
+
+// build returns the client options of the endpoint, headers and transport security shared by every exporter
+func (c otlpClientOptions[T]) build(opts *TelemetryOptions) []T {
+	clientOpts := []T{c.withHeaders(opts.Headers)}
+	if opts.Endpoint != "" {
+		clientOpts = append(clientOpts, c.withEndpoint(opts.Endpoint))
+	}
+	switch {
+	case opts.Insecure:
+		clientOpts = append(clientOpts, c.withInsecure())
+	case opts.TLSConfig != nil:
+		clientOpts = append(clientOpts, c.withTLSConfig(opts.TLSConfig))
+	}
+	return clientOpts
+}
+
+var (
+	otlpTraceGRPCOptions = otlpClientOptions[otlptracegrpc.Option]{
+		withHeaders:  otlptracegrpc.WithHeaders,
+		withEndpoint: otlptracegrpc.WithEndpoint,
+		withInsecure: otlptracegrpc.WithInsecure,
+		withTLSConfig: func(config *tls.Config) otlptracegrpc.Option {
+			return otlptracegrpc.WithTLSCredentials(credentials.NewTLS(config))
+		},
+	}
+	otlpTraceHTTPOptions = otlpClientOptions[otlptracehttp.Option]{
+		withHeaders:   otlptracehttp.WithHeaders,
+		withEndpoint:  otlptracehttp.WithEndpoint,
+		withInsecure:  otlptracehttp.WithInsecure,
+		withTLSConfig: otlptracehttp.WithTLSClientConfig,
+	}
+	otlpMetricGRPCOptions = otlpClientOptions[otlpmetricgrpc.Option]{
+		withHeaders:  otlpmetricgrpc.WithHeaders,
+		withEndpoint: otlpmetricgrpc.WithEndpoint,
+		withInsecure: otlpmetricgrpc.WithInsecure,
+		withTLSConfig: func(config *tls.Config) otlpmetricgrpc.Option {
+			return otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(config))
+		},
+	}
+	otlpMetricHTTPOptions = otlpClientOptions[otlpmetrichttp.Option]{
+		withHeaders:   otlpmetrichttp.WithHeaders,
+		withEndpoint:  otlpmetrichttp.WithEndpoint,
+		withInsecure:  otlpmetrichttp.WithInsecure,
+		withTLSConfig: otlpmetrichttp.WithTLSClientConfig,
+	}
+	otlpLogGRPCOptions = otlpClientOptions[otlploggrpc.Option]{
+		withHeaders:  otlploggrpc.WithHeaders,
+		withEndpoint: otlploggrpc.WithEndpoint,
+		withInsecure: otlploggrpc.WithInsecure,
+		withTLSConfig: func(config *tls.Config) otlploggrpc.Option {
+			return otlploggrpc.WithTLSCredentials(credentials.NewTLS(config))
+		},
+	}
+	otlpLogHTTPOptions = otlpClientOptions[otlploghttp.Option]{
+		withHeaders:   otlploghttp.WithHeaders,
+		withEndpoint:  otlploghttp.WithEndpoint,
+		withInsecure:  otlploghttp.WithInsecure,
+		withTLSConfig: otlploghttp.WithTLSClientConfig,
+	}
+)
+
+func newSpanExporter(ctx context.Context, opts *TelemetryOptions) (sdktrace.SpanExporter, error) {
+	switch opts.Exporter {
+	case TelemetryExporterOTLPGRPC:
+		return otlptracegrpc.New(ctx, otlpTraceGRPCOptions.build(opts)...)
+	case TelemetryExporterOTLPHTTP:
+		return otlptracehttp.New(ctx, otlpTraceHTTPOptions.build(opts)...)
+	case TelemetryExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(opts.Writer))
+	default:
+		return nil, fmt.Errorf("unknown exporter %q", opts.Exporter)
+	}
+}
+
+func newMetricExporter(ctx context.Context, opts *TelemetryOptions) (sdkmetric.Exporter, error) {
+	switch opts.Exporter {
+	case TelemetryExporterOTLPGRPC:
+		return otlpmetricgrpc.New(ctx, otlpMetricGRPCOptions.build(opts)...)
+	case TelemetryExporterOTLPHTTP:
+		return otlpmetrichttp.New(ctx, otlpMetricHTTPOptions.build(opts)...)
+	case TelemetryExporterStdout:
+		return stdoutmetric.New(stdoutmetric.WithWriter(opts.Writer))
+	default:
+		return nil, fmt.Errorf("unknown exporter %q", opts.Exporter)
+	}
+}
+
+func newLogExporter(ctx context.Context, opts *TelemetryOptions) (sdklog.Exporter, error) {
+	switch opts.Exporter {
+	case TelemetryExporterOTLPGRPC:
+		return otlploggrpc.New(ctx, otlpLogGRPCOptions.build(opts)...)
+	case TelemetryExporterOTLPHTTP:
+		return otlploghttp.New(ctx, otlpLogHTTPOptions.build(opts)...)
+	case TelemetryExporterStdout:
+		return stdoutlog.New(stdoutlog.WithWriter(opts.Writer))
+	default:
+		return nil, fmt.Errorf("unknown exporter %q", opts.Exporter)
+	}
+}
+
+func applyTelemetryOptions(opt *TelemetryOptions) *TelemetryOptions {
+	if opt == nil {
+		opt = &TelemetryOptions{}
+	}
+
+	// if error occurs, also return options from input
+	_ = mergo.Merge(opt, *defaultTelemetryOptions)
+	return opt
+}
@@ -0,0 +1,139 @@
+package connect
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// restoreGlobalProviders restores the global providers replaced by InitTelemetry
+func restoreGlobalProviders(t *testing.T) {
+	previousTracerProvider := otel.GetTracerProvider()
+	previousMeterProvider := otel.GetMeterProvider()
+	previousPropagator := otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousTracerProvider)
+		otel.SetMeterProvider(previousMeterProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+}
+
+// inMemoryExporter keeps the exported spans after shutdown
+type inMemoryExporter struct {
+	*tracetest.InMemoryExporter
+}
+
+func (e inMemoryExporter) Shutdown(_ context.Context) error { return nil }
+
+func TestInitTelemetry(t *testing.T) {
+	t.Run("in memory exporter", func(t *testing.T) {
+		restoreGlobalProviders(t)
+		t.Setenv(k8sPodNameEnv, "pod-1")
+
+		exporter := inMemoryExporter{tracetest.NewInMemoryExporter()}
+		reader := sdkmetric.NewManualReader()
+		shutdown, err := InitTelemetry(context.Background(), &TelemetryOptions{
+			ServiceName:        "service",
+			ServiceVersion:     "v1.0.0",
+			Environment:        "test",
+			ResourceAttributes: map[string]string{"team": "platform"},
+			SpanExporter:       exporter,
+			UseMetrics:         true,
+			MetricReader:       reader,
+		})
+		require.NoError(t, err)
+
+		_, span := otel.Tracer("test").Start(context.Background(), "span")
+		span.End()
+		require.NoError(t, shutdown(context.Background()))
+
+		spans := exporter.GetSpans()
+		require.Len(t, spans, 1)
+
+		attrs := map[attribute.Key]string{}
+		for _, attr := range spans[0].Resource.Attributes() {
+			attrs[attr.Key] = attr.Value.Emit()
+		}
+		assert.Equal(t, "service", attrs["service.name"])
+		assert.Equal(t, "v1.0.0", attrs["service.version"])
+		assert.Equal(t, "test", attrs["deployment.environment"])
+		assert.Equal(t, "pod-1", attrs["k8s.pod.name"])
+		assert.Equal(t, "platform", attrs["team"])
+		assert.NotEmpty(t, attrs["host.name"])
+	})
+
+	t.Run("service name from env", func(t *testing.T) {
+		restoreGlobalProviders(t)
+		t.Setenv("OTEL_SERVICE_NAME", "env-service")
+		t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "deployment.environment=staging")
+
+		exporter := inMemoryExporter{tracetest.NewInMemoryExporter()}
+		shutdown, err := InitTelemetry(context.Background(), &TelemetryOptions{SpanExporter: exporter})
+		require.NoError(t, err)
+
+		_, span := otel.Tracer("test").Start(context.Background(), "span")
+		span.End()
+		require.NoError(t, shutdown(context.Background()))
+
+		spans := exporter.GetSpans()
+		require.Len(t, spans, 1)
+
+		attrs := map[attribute.Key]string{}
+		for _, attr := range spans[0].Resource.Attributes() {
+			attrs[attr.Key] = attr.Value.Emit()
+		}
+		assert.Equal(t, "env-service", attrs["service.name"])
+		assert.Equal(t, "staging", attrs["deployment.environment"])
+	})
+
+	t.Run("stdout exporter", func(t *testing.T) {
+		restoreGlobalProviders(t)
+
+		var buf bytes.Buffer
+		shutdown, err := InitTelemetry(context.Background(), &TelemetryOptions{
+			ServiceName: "service",
+			Exporter:    TelemetryExporterStdout,
+			Writer:      &buf,
+		})
+		require.NoError(t, err)
+
+		_, span := otel.Tracer("test").Start(context.Background(), "stdout-span")
+		span.End()
+		require.NoError(t, shutdown(context.Background()))
+
+		assert.Contains(t, buf.String(), "stdout-span")
+	})
+
+	t.Run("explicit zero trace ratio", func(t *testing.T) {
+		restoreGlobalProviders(t)
+
+		exporter := inMemoryExporter{tracetest.NewInMemoryExporter()}
+		traceRatio := 0.0
+		shutdown, err := InitTelemetry(context.Background(), &TelemetryOptions{
+			ServiceName:  "service",
+			SpanExporter: exporter,
+			TraceRatio:   &traceRatio,
+		})
+		require.NoError(t, err)
+
+		_, span := otel.Tracer("test").Start(context.Background(), "span")
+		span.End()
+		require.NoError(t, shutdown(context.Background()))
+
+		assert.Empty(t, exporter.GetSpans())
+	})
+
+	t.Run("unknown exporter", func(t *testing.T) {
+		restoreGlobalProviders(t)
+
+		_, err := InitTelemetry(context.Background(), &TelemetryOptions{Exporter: "zipkin"})
+		assert.ErrorContains(t, err, `unknown exporter "zipkin"`)
+	})
+}
@@ -5,52 +5,27 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
-	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 // InitTraceProvider configures an OpenTelemetry exporter and trace provider
+//
+// Deprecated: use InitTelemetry, it returns an error instead of exiting and supports other exporters.
 func InitTraceProvider(token, collectorURL, serviceName, environment string, traceRatio float64) *sdktrace.TracerProvider {
-	headers := map[string]string{
-		"signoz-access-token": token,
-	}
-
-	exporter, err := otlptrace.New(
-		context.Background(),
-		otlptracegrpc.NewClient(
-			otlptracegrpc.WithInsecure(),
-			otlptracegrpc.WithEndpoint(collectorURL),
-			otlptracegrpc.WithHeaders(headers),
-		),
-	)
-
+	t, err := initTelemetry(context.Background(), applyTelemetryOptions(&TelemetryOptions{
+		ServiceName: serviceName,
+		Environment: environment,
+		Exporter:    TelemetryExporterOTLPGRPC,
+		Endpoint:    collectorURL,
+		Insecure:    true,
+		Headers: map[string]string{
+			"signoz-access-token": token,
+		},
+		Sampler: NewSampler(&SamplerOptions{Ratio: traceRatio}),
+	}))
 	if err != nil {
 		log.Fatal(err)
 	}
-	resources, err := resource.New(
-		context.Background(),
-		resource.WithAttributes(
-			attribute.String("service.name", serviceName),
-			attribute.String("deployment.environment", environment),
-		),
-	)
-	if err != nil {
-		log.Printf("Could not set resources: %v", err)
-	}
-
-	traceProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(sdktrace.TraceIDRatioBased(traceRatio)),
-		sdktrace.WithBatcher(exporter),
-		sdktrace.WithResource(resources),
-	)
-
-	otel.SetTracerProvider(traceProvider)
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
 
-	return traceProvider
+	return t.tracerProvider
 }
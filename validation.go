@@ -0,0 +1,38 @@
+package connect
+
+import (
+	"errors"
+	"fmt"
+)
+
+// optionsValidator collects the invalid fields of an options struct
+type optionsValidator struct {
+	errs []error
+}
+
+// check records the field as invalid when valid is false
+func (v *optionsValidator) check(valid bool, field, message string) {
+	if !valid {
+		v.errs = append(v.errs, fmt.Errorf("%s: %s", field, message))
+	}
+}
+
+// nested records the errors of a nested options Validate, prefixed with the field name
+func (v *optionsValidator) nested(field string, err error) {
+	if err == nil {
+		return
+	}
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			v.nested(field, e)
+		}
+		return
+	}
+	v.errs = append(v.errs, fmt.Errorf("%s.%w", field, err))
+}
+
+// err returns the joined errors, nil when every field is valid
+func (v *optionsValidator) err() error {
+	return errors.Join(v.errs...)
+}
@@ -0,0 +1,156 @@
+package connect
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptionsValidate(t *testing.T) {
+	invalidTraceRatio := 1.5
+	tests := []struct {
+		name   string
+		opt    interface{ Validate() error }
+		errors []string
+	}{
+		{
+			name: "valid default options",
+			opt:  applyRedisConnectionPoolOptions(nil),
+		},
+		{
+			name: "redis",
+			opt:  &RedisConnectionPoolOptions{PoolSize: -1, IdleCount: 20, ReadTimeout: -3},
+			errors: []string{
+				"ReadTimeout: must be -1, -2 or not negative",
+				"PoolSize: must not be negative",
+			},
+		},
+		{
+			name: "mysql",
+			opt:  &MySQLConnectionOptions{PingInterval: time.Second, PingTimeout: time.Second, MaxIdleConns: 10, MaxOpenConns: 5, LogLevel: "debug"},
+			errors: []string{
+				"MaxIdleConns: must not be greater than MaxOpenConns",
+				`LogLevel: unknown log level "debug", must be one of info, warn or error`,
+			},
+		},
+		{
+			name: "cockroach",
+			opt:  &CockroachDBConnectionOptions{PingTimeout: time.Second},
+			errors: []string{
+				"PingInterval: must be positive",
+			},
+		},
+		{
+			name: "http",
+			opt:  &HTTPConnectionOptions{Timeout: -time.Second, UseCircuitBreaker: true, CircuitBreakerConfig: &CircuitSetting{ErrorPercentThreshold: 120}},
+			errors: []string{
+				"Timeout: must not be negative",
+				"CircuitBreakerConfig.ErrorPercentThreshold: must be between 0 and 100",
+			},
+		},
+		{
+			name: "elasticsearch",
+			opt:  &ElasticsearchConnectionOptions{MaxConnsPerHost: -1},
+			errors: []string{
+				"MaxConnsPerHost: must not be negative",
+			},
+		},
+		{
+			name: "grpc",
+			opt: &GRPCUnaryInterceptorOptions{
+				RetryCount:  3,
+				RateLimiter: &GRPCRateLimiter{Period: time.Second},
+				RetryPolicy: &GRPCRetryPolicy{BackoffMultiplier: 0.5, Budget: &GRPCRetryBudget{Ratio: 2}},
+				MethodPolicies: GRPCMethodPolicies{
+					"pkg.Service/Method": {Timeout: -time.Second},
+				},
+			},
+			errors: []string{
+				"RetryInterval: must be positive when RetryCount is set",
+				"RateLimiter.Limit: must be positive",
+				"RetryPolicy.BackoffMultiplier: must be at least 1",
+				"RetryPolicy.Budget.Ratio: must be between 0 and 1",
+				"MethodPolicies.[pkg.Service/Method]: must be *, /pkg.Service/* or /pkg.Service/Method",
+				"MethodPolicies.[pkg.Service/Method].Timeout: must not be negative",
+			},
+		},
+		{
+			name: "telemetry",
+			opt:  &TelemetryOptions{Exporter: TelemetryExporterStdout, TraceRatio: &invalidTraceRatio, MetricInterval: time.Second},
+			errors: []string{
+				"TraceRatio: must be between 0 and 1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opt.Validate()
+			if len(tt.errors) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			require.Error(t, err)
+			joined, ok := err.(interface{ Unwrap() []error })
+			require.True(t, ok)
+
+			var messages []string
+			for _, e := range joined.Unwrap() {
+				messages = append(messages, e.Error())
+			}
+			assert.Equal(t, tt.errors, messages)
+		})
+	}
+}
+
+func TestNewRedigoRedisConnectionPool_invalidOptions(t *testing.T) {
+	_, err := NewRedigoRedisConnectionPool("redis://localhost:6379", &RedisConnectionPoolOptions{PoolSize: 10, IdleCount: 20})
+	assert.ErrorContains(t, err, "invalid redis connection pool options: IdleCount: must not be greater than PoolSize")
+}
+
+func TestNewInterceptors_invalidOptions(t *testing.T) {
+	invalid := &GRPCUnaryInterceptorOptions{Timeout: -time.Second}
+
+	_, err := NewUnaryClientInterceptor(invalid)
+	assert.ErrorContains(t, err, "invalid gRPC client interceptor options: Timeout: must not be negative")
+	_, err = NewStreamClientInterceptor(invalid)
+	assert.ErrorContains(t, err, "invalid gRPC client interceptor options: Timeout: must not be negative")
+	_, err = NewUnaryServerInterceptor(invalid, nil)
+	assert.ErrorContains(t, err, "invalid gRPC server interceptor options: Timeout: must not be negative")
+	_, err = NewStreamServerInterceptor(invalid, nil)
+	assert.ErrorContains(t, err, "invalid gRPC server interceptor options: Timeout: must not be negative")
+
+	// the legacy constructors only log the error
+	assert.NotNil(t, UnaryClientInterceptor(invalid))
+	assert.NotNil(t, UnaryServerInterceptor(invalid, nil))
+}
+
+func TestNewServerInterceptors_clientOnlyOptions(t *testing.T) {
+	// the retry options are not used by the server interceptors, so they're not validated
+	opts := &GRPCUnaryInterceptorOptions{RetryCount: 3, Timeout: time.Second}
+
+	_, err := NewUnaryServerInterceptor(opts, nil)
+	assert.NoError(t, err)
+	_, err = NewStreamServerInterceptor(opts, nil)
+	assert.NoError(t, err)
+
+	_, err = NewUnaryServerInterceptor(nil, nil)
+	assert.NoError(t, err)
+}
+
+func TestNewHTTPConnectionE_invalidOptions(t *testing.T) {
+	_, err := NewHTTPConnectionE(&HTTPConnectionOptions{Timeout: -time.Second})
+	assert.ErrorContains(t, err, "invalid HTTP connection options: Timeout: must not be negative")
+
+	client, err := NewHTTPConnectionE(&HTTPConnectionOptions{UseCircuitBreaker: true})
+	assert.NoError(t, err)
+	assert.NotNil(t, client)
+}
+
+func TestNewSlidingWindowCircuitBreaker_invalidOptions(t *testing.T) {
+	_, err := NewSlidingWindowCircuitBreaker(&SlidingWindowCircuitBreakerOptions{ConsecutiveFailures: -1})
+	assert.ErrorContains(t, err, "invalid sliding window circuit breaker options")
+}